@@ -0,0 +1,172 @@
+package gfont
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"unicode"
+
+	"github.com/imacks/gfont/sfnt"
+)
+
+// Audit is the result of Downloader.Audit
+type Audit struct {
+	Fonts []FontAudit `json:"fonts"`
+}
+
+// FontAudit compares the unicode range of a font with the characters its font file has a glyph for
+type FontAudit struct {
+	Family string    `json:"family"`
+	Style  string    `json:"style"`
+	Weight AxisValue `json:"weight"`
+	Format string    `json:"format"`
+	Subset string    `json:"subset,omitempty"`
+	URL    string    `json:"url"`
+	// Path is relative to the download directory, with forward slashes
+	Path string `json:"path"`
+	// Runes is the number of characters the font file has a glyph for
+	Runes int `json:"runes"`
+	// Missing are the characters of the unicode range that the font file has no glyph for. Control, format, private
+	// use and unassigned code points are not expected to have one.
+	Missing UnicodeRange `json:"missing,omitempty"`
+	// Extra are the characters the font file has a glyph for outside of the unicode range. Browsers never use the font
+	// for them.
+	Extra UnicodeRange `json:"extra,omitempty"`
+}
+
+// OK reports whether every font file has a glyph for each character of its unicode range
+func (a *Audit) OK() bool {
+	for _, f := range a.Fonts {
+		if len(f.Missing) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Audit downloads the font files of ts, or reads those already downloaded, and compares the characters of each with its
+// unicode range, the promise the CSS makes. The first URL of each font is audited if it is a WOFF2, WOFF, TrueType or
+// OpenType file; EOT and SVG fonts are skipped. Fonts without a unicode range are used for every character they have,
+// so only their number of characters is reported.
+func (d *Downloader) Audit(ctx context.Context, ts *Typefaces) (*Audit, error) {
+	audited := &Typefaces{Fonts: []Typeface{}}
+	for _, t := range ts.Fonts {
+		if t.URL == nil {
+			continue
+		}
+		switch fontExtensions[t.Format] {
+		case "woff2", "woff", "ttf", "otf":
+			audited.Fonts = append(audited.Fonts, t.withSource(Source{URL: t.URL, Format: t.Format}))
+		}
+	}
+	if _, err := d.Download(ctx, audited); err != nil {
+		return nil, err
+	}
+
+	result := &Audit{Fonts: []FontAudit{}}
+	for _, t := range audited.Fonts {
+		relPath, err := d.Path(&t)
+		if err != nil {
+			return nil, err
+		}
+		fullPath, err := d.fullPath(relPath)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadFile(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		fa, err := auditFont(&t, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t.URL.String(), err)
+		}
+		fa.Path = relPath
+		result.Fonts = append(result.Fonts, *fa)
+	}
+	return result, nil
+}
+
+// auditFont compares the unicode range of t with the cmap of its font file
+func auditFont(t *Typeface, data []byte) (*FontAudit, error) {
+	data, err := sfnt.Decode(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	cmap, err := font.Cmap()
+	if err != nil {
+		return nil, err
+	}
+
+	fa := &FontAudit{
+		Family: t.Family,
+		Style:  t.FontStyle(),
+		Weight: t.Weight,
+		Format: t.Format,
+		Subset: t.Subset,
+		URL:    t.URL.String(),
+		Runes:  cmap.Len(),
+	}
+	if len(t.UnicodeRange) == 0 {
+		return fa, nil
+	}
+
+	fa.Missing = MissingRunes(t.UnicodeRange, cmap)
+	fa.Extra = cmapRange(cmap).Subtract(t.UnicodeRange)
+	return fa, nil
+}
+
+// MissingRunes returns the characters of wanted that cmap has no glyph for. Control, format, private use and unassigned
+// code points are not expected to have one, and are left out.
+func MissingRunes(wanted UnicodeRange, cmap *sfnt.Cmap) UnicodeRange {
+	return wanted.Intersect(graphicRange()).Subtract(cmapRange(cmap))
+}
+
+// cmapRange returns the characters cmap has a glyph for
+func cmapRange(cmap *sfnt.Cmap) UnicodeRange {
+	ranges := []RuneRange{}
+	for _, r := range cmap.Runes() {
+		if n := len(ranges); n > 0 && ranges[n-1].Last == r-1 {
+			ranges[n-1].Last = r
+		} else {
+			ranges = append(ranges, RuneRange{First: r, Last: r})
+		}
+	}
+	return NewUnicodeRange(ranges...)
+}
+
+var (
+	graphicOnce   sync.Once
+	graphicRanges UnicodeRange
+)
+
+// graphicRange returns the characters unicode.IsGraphic reports true for
+func graphicRange() UnicodeRange {
+	graphicOnce.Do(func() {
+		ranges := []RuneRange{}
+		add := func(lo, hi, stride rune) {
+			if stride == 1 {
+				ranges = append(ranges, RuneRange{First: lo, Last: hi})
+				return
+			}
+			for c := lo; c <= hi; c += stride {
+				ranges = append(ranges, RuneRange{First: c, Last: c})
+			}
+		}
+		for _, table := range unicode.GraphicRanges {
+			for _, r := range table.R16 {
+				add(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+			}
+			for _, r := range table.R32 {
+				add(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+			}
+		}
+		graphicRanges = NewUnicodeRange(ranges...)
+	})
+	return graphicRanges
+}
@@ -0,0 +1,113 @@
+package gfont
+
+import (
+	"encoding/binary"
+	"net/url"
+	"testing"
+	"unicode"
+
+	"github.com/imacks/gfont/sfnt"
+)
+
+// cmapFont is a font file with only a cmap table, mapping A-C and U+00E9
+func cmapFont() []byte {
+	return sfnt.Build(sfnt.TrueType, map[string][]byte{"cmap": cmapTable()})
+}
+
+// cmapTable is a cmap table mapping A-C to glyphs 1-3 and U+00E9 to glyph 4
+func cmapTable() []byte {
+	groups := [][3]uint32{{0x41, 0x43, 1}, {0xE9, 0xE9, 4}}
+	cmap := make([]byte, 12+16+12*len(groups))
+	binary.BigEndian.PutUint16(cmap[2:], 1)
+	binary.BigEndian.PutUint16(cmap[4:], 3)
+	binary.BigEndian.PutUint16(cmap[6:], 10)
+	binary.BigEndian.PutUint32(cmap[8:], 12)
+	binary.BigEndian.PutUint16(cmap[12:], 12)
+	binary.BigEndian.PutUint32(cmap[16:], uint32(16+12*len(groups)))
+	binary.BigEndian.PutUint32(cmap[24:], uint32(len(groups)))
+	for i, g := range groups {
+		for j, v := range g {
+			binary.BigEndian.PutUint32(cmap[28+12*i+4*j:], v)
+		}
+	}
+	return cmap
+}
+
+func TestAuditFont(t *testing.T) {
+	u, _ := url.Parse("https://fonts.gstatic.com/s/domine/v20/L0x8DFMnlVwD4h3hu_qaIhs.ttf")
+	face := &Typeface{Family: "Domine", Style: "normal", Weight: AxisPoint(400), Format: "truetype", URL: u, Subset: "latin",
+		UnicodeRange: mustParseUnicodeRange(t, "U+0000-007F")}
+
+	fa, err := auditFont(face, cmapFont())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fa.Runes != 4 || fa.Family != "Domine" || fa.Subset != "latin" {
+		t.Errorf("bad audit %+v", fa)
+	}
+	// control characters are not expected to have a glyph
+	if got := fa.Missing.String(); got != "U+0020-0040, U+0044-007E" {
+		t.Errorf("got missing %s", got)
+	}
+	if got := fa.Extra.String(); got != "U+00E9" {
+		t.Errorf("got extra %s", got)
+	}
+	if (&Audit{Fonts: []FontAudit{*fa}}).OK() {
+		t.Errorf("audit with missing characters should not be OK")
+	}
+
+	// every code point
+	face.UnicodeRange = allRunes
+	fa, err = auditFont(face, cmapFont())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fa.Missing.Contains(0x4E00) || fa.Missing.Contains(0xE000) || fa.Missing.Contains(0x10FFFF) || fa.Missing.Contains('A') || len(fa.Extra) > 0 {
+		t.Errorf("got missing %s, extra %s", fa.Missing, fa.Extra)
+	}
+
+	face.UnicodeRange = mustParseUnicodeRange(t, "U+0000-007F")
+	fa, _ = auditFont(face, cmapFont())
+	if (&Audit{Fonts: []FontAudit{*fa}}).OK() {
+		t.Errorf("audit with missing characters should not be OK")
+	}
+
+	// without a unicode range, the font is used for all its characters
+	face.UnicodeRange = nil
+	fa, err = auditFont(face, cmapFont())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fa.Runes != 4 || len(fa.Missing) > 0 || len(fa.Extra) > 0 {
+		t.Errorf("bad audit %+v", fa)
+	}
+
+	if _, err := auditFont(face, []byte("not a font")); err == nil {
+		t.Errorf("expect error")
+	}
+}
+
+func TestGraphicRange(t *testing.T) {
+	graphic := graphicRange()
+	for r := rune(0); r <= unicode.MaxRune; r++ {
+		if graphic.Contains(r) != unicode.IsGraphic(r) {
+			t.Fatalf("U+%04X: got %v, want %v", r, graphic.Contains(r), unicode.IsGraphic(r))
+		}
+	}
+}
+
+func TestMissingRunes(t *testing.T) {
+	font, err := sfnt.Parse(cmapFont())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmap, err := font.Cmap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// tab, soft hyphen and private use characters are not expected to have a glyph
+	got := MissingRunes(mustParseUnicodeRange(t, "U+0009, U+0041-0045, U+00AD, U+00E9, U+E000-F8FF"), cmap)
+	if got.String() != "U+0044-0045" {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,112 @@
+package gfont
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrCacheMiss is returned in offline mode when a response is not in the cache
+var ErrCacheMiss = errors.New("not in cache")
+
+// Cache stores API responses on disk, keyed by URL and user-agent profile
+type Cache struct {
+	// Dir is the cache directory. It is created on first write.
+	Dir string
+	// TTL is how long a response is served without asking the server. 0 revalidates every time.
+	TTL time.Duration
+	// Offline serves only from cache, regardless of TTL, and never sends a request.
+	Offline bool
+}
+
+// cacheEntry is the metadata stored next to a cached response body
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	Profile      string    `json:"profile"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// NewCache returns a Cache stored in dir
+func NewCache(dir string, ttl time.Duration) *Cache {
+	return &Cache{Dir: dir, TTL: ttl}
+}
+
+// Clear removes every cached response
+func (c *Cache) Clear() error {
+	return os.RemoveAll(c.Dir)
+}
+
+func (c *Cache) key(ua FontProfile, uaString, rawURL string) string {
+	sum := sha256.Sum256([]byte(ua.String() + "\n" + uaString + "\n" + rawURL))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) fresh(entry *cacheEntry) bool {
+	return c.TTL > 0 && time.Since(entry.FetchedAt) < c.TTL
+}
+
+func (c *Cache) load(key string) (*cacheEntry, []byte, error) {
+	metaBytes, err := ioutil.ReadFile(filepath.Join(c.Dir, key+".json"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(metaBytes, entry); err != nil {
+		return nil, nil, err
+	}
+
+	body, err := ioutil.ReadFile(filepath.Join(c.Dir, key+".css"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entry, body, nil
+}
+
+func (c *Cache) store(key string, entry *cacheEntry, body []byte) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+
+	metaBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// body goes first so that a readable entry always has its body
+	if err := writeFileAtomic(filepath.Join(c.Dir, key+".css"), body); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(c.Dir, key+".json"), metaBytes)
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,100 @@
+package gfont
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCacheTTL(t *testing.T) {
+	requests := 0
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("body"))
+	})
+	c.Cache = NewCache(t.TempDir(), time.Hour)
+
+	for i := 0; i < 2; i++ {
+		body, err := c.DownloadCSS(context.Background(), TTF, "Domine", "")
+		if err != nil || string(body) != "body" {
+			t.Fatalf("got %q, %v", body, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests within TTL, want 1", requests)
+	}
+
+	// each profile has its own entry
+	if _, err := c.DownloadCSS(context.Background(), WOFF2, "Domine", ""); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	var gotETag, gotModified string
+	requests := 0
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		gotETag, gotModified = r.Header.Get("If-None-Match"), r.Header.Get("If-Modified-Since")
+		if gotETag == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Write([]byte("body"))
+	})
+	// a TTL of 0 revalidates every time
+	c.Cache = NewCache(t.TempDir(), 0)
+
+	if _, err := c.DownloadCSS(context.Background(), TTF, "Domine", ""); err != nil {
+		t.Fatal(err)
+	}
+	if gotETag != "" || gotModified != "" {
+		t.Errorf("first request sent validators %q %q", gotETag, gotModified)
+	}
+
+	body, err := c.DownloadCSS(context.Background(), TTF, "Domine", "")
+	if err != nil || string(body) != "body" {
+		t.Fatalf("got %q, %v", body, err)
+	}
+	if requests != 2 || gotETag != `"v1"` || gotModified != "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Errorf("got %d requests with validators %q %q", requests, gotETag, gotModified)
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	requests := 0
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("body"))
+	})
+	c.Cache = NewCache(t.TempDir(), 0)
+	if _, err := c.DownloadCSS(context.Background(), TTF, "Domine", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Cache.Offline = true
+	body, err := c.DownloadCSS(context.Background(), TTF, "Domine", "")
+	if err != nil || string(body) != "body" {
+		t.Errorf("got %q, %v", body, err)
+	}
+	if _, err := c.DownloadCSS(context.Background(), TTF, "Roboto", ""); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("got %v, want ErrCacheMiss", err)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+
+	if err := c.Cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DownloadCSS(context.Background(), TTF, "Domine", ""); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("got %v after Clear, want ErrCacheMiss", err)
+	}
+}
@@ -0,0 +1,327 @@
+package gfont
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client downloads font-face CSS from Google API
+type Client struct {
+	// HTTPClient sends the requests. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+	// BaseURL is the css2 API endpoint. The Google API is used if nil.
+	BaseURL *url.URL
+	// UserAgents maps each font profile to a user-agent string. DefaultUserAgents is used if nil.
+	UserAgents map[FontProfile]string
+	// MaxConcurrency limits parallel requests in DownloadAll. defaultConcurrency is used if less than 1.
+	MaxConcurrency int
+	// Cache stores responses on disk. Responses are not cached if nil.
+	Cache *Cache
+	// Retry controls how failed requests are retried. Requests are not retried if nil.
+	Retry *RetryPolicy
+	// Limiter limits requests per second across all downloads made with this client. There is no limit if nil.
+	Limiter *RateLimiter
+}
+
+const defaultConcurrency = 4
+
+// NewClient returns a Client that uses http.DefaultClient and the Google API
+func NewClient() *Client {
+	return &Client{}
+}
+
+// DefaultUserAgents returns a copy of the built-in user-agent table
+func DefaultUserAgents() map[FontProfile]string {
+	result := make(map[FontProfile]string, len(useragent))
+	for k, v := range useragent {
+		result[k] = v
+	}
+	return result
+}
+
+// GetURL returns the URL for font-face CSS
+func (c *Client) GetURL(ua FontProfile, fontFamily, fontStyle string) string {
+	if _, ok := c.userAgent(ua); !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("%s?family=%s:%s", c.baseURL(), strings.Replace(fontFamily, " ", "+", -1), fontStyle)
+}
+
+// DownloadCSS downloads font-face CSS. A non-2xx response is returned as *APIError.
+func (c *Client) DownloadCSS(ctx context.Context, ua FontProfile, fontFamily, fontStyle string) ([]byte, error) {
+	if _, ok := c.userAgent(ua); !ok {
+		return nil, fmt.Errorf("ua unsupported")
+	}
+
+	return c.get(ctx, ua, c.GetURL(ua, fontFamily, fontStyle))
+}
+
+// QueryURL returns the canonical URL for a Query
+func (c *Client) QueryURL(q *Query) string {
+	return q.URL(c.baseURL())
+}
+
+// Download validates q locally, then downloads its font-face CSS
+func (c *Client) Download(ctx context.Context, ua FontProfile, q *Query) ([]byte, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c.get(ctx, ua, c.QueryURL(q))
+}
+
+// DownloadAll downloads and parses the CSS of q for each profile in parallel. CompatProfiles is used if no profile is
+// given. Each Typeface is tagged with the profile it came from, and typefaces sharing a URL are kept only once, in
+// profile order. If q has a text, every Typeface is marked as a text subset.
+func (c *Client) DownloadAll(ctx context.Context, q *Query, profiles ...FontProfile) (*Typefaces, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	if len(profiles) == 0 {
+		profiles = CompatProfiles
+	}
+
+	results := make([]Typefaces, len(profiles))
+	err := c.forEach(ctx, len(profiles), func(ctx context.Context, i int) error {
+		cssBytes, err := c.Download(ctx, profiles[i], q)
+		if err == nil {
+			err = UnmarshalCSS(cssBytes, &results[i])
+		}
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", profiles[i], err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &Typefaces{Fonts: []Typeface{}}
+	seen := map[string]bool{}
+	for i, ts := range results {
+		for _, t := range ts.Fonts {
+			key := t.String()
+			if t.URL != nil {
+				key = t.URL.String()
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			t.Profile = profiles[i].String()
+			t.TextSubset = t.TextSubset || q.Text != ""
+			merged.Fonts = append(merged.Fonts, t)
+		}
+	}
+	return merged, nil
+}
+
+// forEach calls fn for 0 to n-1 in parallel, up to c.MaxConcurrency at a time. It stops at the first error and
+// returns it.
+func (c *Client) forEach(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, n)
+	sem := make(chan struct{}, c.concurrency())
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if ctx.Err() != nil {
+				errs[i] = ctx.Err()
+				return
+			}
+			if err := fn(ctx, i); err != nil {
+				errs[i] = err
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// report the error that caused the cancellation, not the cancellations
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchFile downloads a font file
+func (c *Client) fetchFile(ctx context.Context, rawURL string) ([]byte, error) {
+	// gstatic serves the same file to every user-agent
+	uaString, ok := c.userAgent(TTF)
+	if !ok {
+		uaString = useragent[TTF]
+	}
+
+	resp, err := c.do(ctx, uaString, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) get(ctx context.Context, ua FontProfile, rawURL string) ([]byte, error) {
+	uaString, ok := c.userAgent(ua)
+	if !ok {
+		return nil, fmt.Errorf("ua unsupported")
+	}
+
+	if c.Cache == nil {
+		resp, err := c.do(ctx, uaString, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Body, nil
+	}
+
+	key := c.Cache.key(ua, uaString, rawURL)
+	entry, cached, errCache := c.Cache.load(key)
+	if errCache == nil && (c.Cache.Offline || c.Cache.fresh(entry)) {
+		return cached, nil
+	}
+	if c.Cache.Offline {
+		return nil, fmt.Errorf("GET %s: %w", rawURL, ErrCacheMiss)
+	}
+
+	header := http.Header{}
+	if errCache == nil {
+		if entry.ETag != "" {
+			header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := c.do(ctx, uaString, rawURL, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && errCache == nil {
+		entry.FetchedAt = time.Now()
+		if errStore := c.Cache.store(key, entry, cached); errStore != nil {
+			return nil, errStore
+		}
+		return cached, nil
+	}
+
+	entry = &cacheEntry{
+		URL:          rawURL,
+		Profile:      ua.String(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+	}
+	if errStore := c.Cache.store(key, entry, resp.Body); errStore != nil {
+		return nil, errStore
+	}
+	return resp.Body, nil
+}
+
+// response is an HTTP response with its body read
+type response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// do sends a GET request, retrying according to c.Retry. Any status other than 2xx and 304 is returned as *APIError.
+func (c *Client) do(ctx context.Context, uaString, rawURL string, header http.Header) (*response, error) {
+	attempts := c.Retry.attempts()
+	for attempt := 1; ; attempt++ {
+		if err := c.Limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.send(ctx, uaString, rawURL, header)
+		if err == nil || attempt >= attempts || !isRetryable(err) {
+			return resp, err
+		}
+
+		if errSleep := sleepContext(ctx, c.Retry.delay(attempt, err)); errSleep != nil {
+			return nil, errSleep
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, uaString, rawURL string, header http.Header) (*response, error) {
+	req, errReq := http.NewRequest("GET", rawURL, nil)
+	if errReq != nil {
+		return nil, errReq
+	}
+	req = req.WithContext(ctx)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("user-agent", uaString)
+
+	httpResp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if httpResp.StatusCode != http.StatusNotModified && (httpResp.StatusCode < 200 || httpResp.StatusCode > 299) {
+		return nil, newAPIError(httpResp.StatusCode, rawURL, httpResp.Header, body)
+	}
+
+	return &response{StatusCode: httpResp.StatusCode, Header: httpResp.Header, Body: body}, nil
+}
+
+func (c *Client) concurrency() int {
+	if c.MaxConcurrency < 1 {
+		return defaultConcurrency
+	}
+	return c.MaxConcurrency
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+func (c *Client) baseURL() string {
+	if c.BaseURL == nil {
+		return apiBaseURL
+	}
+	return c.BaseURL.String()
+}
+
+func (c *Client) userAgent(ua FontProfile) (string, bool) {
+	table := c.UserAgents
+	if table == nil {
+		table = useragent
+	}
+	v, ok := table[ua]
+	return v, ok
+}
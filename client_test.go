@@ -0,0 +1,143 @@
+package gfont
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newTestClient returns a Client sending its css2 requests to a test server running handler
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *httptest.Server) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	base, err := url.Parse(srv.URL + "/css2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Client{HTTPClient: srv.Client(), BaseURL: base}, srv
+}
+
+// countingTransport counts the requests sent through it
+type countingTransport struct {
+	requests int
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientDownloadCSS(t *testing.T) {
+	var gotPath, gotQuery, gotUA string
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery, gotUA = r.URL.Path, r.URL.RawQuery, r.UserAgent()
+		w.Write([]byte("@font-face{}"))
+	})
+	transport := &countingTransport{}
+	c.HTTPClient = &http.Client{Transport: transport}
+
+	body, err := c.DownloadCSS(context.Background(), WOFF2, "Open Sans", "wght@400")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "@font-face{}" {
+		t.Errorf("got body %q", body)
+	}
+	if gotPath != "/css2" || gotQuery != "family=Open+Sans:wght@400" {
+		t.Errorf("got request %s?%s", gotPath, gotQuery)
+	}
+	if gotUA != useragent[WOFF2] {
+		t.Errorf("got user-agent %q, want %q", gotUA, useragent[WOFF2])
+	}
+	if transport.requests != 1 {
+		t.Errorf("injected http client sent %d requests, want 1", transport.requests)
+	}
+}
+
+func TestClientUserAgents(t *testing.T) {
+	var gotUA string
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.UserAgent()
+	})
+	c.UserAgents = map[FontProfile]string{TTF: "custom-agent"}
+
+	if _, err := c.DownloadCSS(context.Background(), TTF, "Domine", ""); err != nil {
+		t.Fatal(err)
+	}
+	if gotUA != "custom-agent" {
+		t.Errorf("got user-agent %q, want custom-agent", gotUA)
+	}
+
+	if _, err := c.DownloadCSS(context.Background(), WOFF2, "Domine", ""); err == nil {
+		t.Errorf("expect error for a profile missing from UserAgents")
+	}
+	if got := c.GetURL(WOFF2, "Domine", ""); got != "" {
+		t.Errorf("got url %s for a profile missing from UserAgents", got)
+	}
+}
+
+func TestClientDefaults(t *testing.T) {
+	c := NewClient()
+	if c.httpClient() != http.DefaultClient {
+		t.Errorf("expect http.DefaultClient")
+	}
+	if got, want := c.GetURL(TTF, "Open Sans", "wght@400"), apiBaseURL+"?family=Open+Sans:wght@400"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	table := DefaultUserAgents()
+	table[TTF] = "changed"
+	if useragent[TTF] == "changed" {
+		t.Errorf("DefaultUserAgents returned the built-in table instead of a copy")
+	}
+}
+
+func TestClientContextCanceled(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := c.DownloadCSS(ctx, TTF, "Domine", ""); err == nil {
+		t.Errorf("expect error for a canceled context")
+	}
+}
+
+func TestClientDownloadAll(t *testing.T) {
+	face := func(weight, file string) string {
+		return "@font-face {\n  font-family: 'Domine';\n  font-style: normal;\n  font-weight: " + weight +
+			";\n  src: url(https://fonts.gstatic.com/s/domine/v20/" + file + ") format('truetype');\n}\n"
+	}
+	css := map[string]string{
+		useragent[WOFF2]: face("400", "a.woff2") + face("700", "shared.ttf"),
+		useragent[TTF]:   face("700", "shared.ttf") + face("400", "c.ttf"),
+	}
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(css[r.UserAgent()]))
+	})
+
+	ts, err := c.DownloadAll(context.Background(), NewQuery().Family("Domine").SetText("Hi"), WOFF2, TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, f := range ts.Fonts {
+		got = append(got, f.Profile+":"+f.FileName())
+		if !f.TextSubset {
+			t.Errorf("%s: expect a text subset", f.FileName())
+		}
+	}
+	if strings.Join(got, " ") != "woff2:a.woff2 woff2:shared.ttf ttf:c.ttf" {
+		t.Errorf("got %v", got)
+	}
+
+	c.UserAgents = map[FontProfile]string{WOFF2: useragent[WOFF2]}
+	if _, err := c.DownloadAll(context.Background(), NewQuery().Family("Domine"), WOFF2, TTF); err == nil ||
+		!strings.HasPrefix(err.Error(), "profile ttf:") {
+		t.Errorf("got %v, want an error for profile ttf", err)
+	}
+}
@@ -0,0 +1,96 @@
+package gfont
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Coverage is the result of Typefaces.ForText
+type Coverage struct {
+	// Fonts are the fonts needed to render the text, in collection order
+	Fonts []Typeface `json:"fonts"`
+	// Missing are the characters of the text that no font covers
+	Missing UnicodeRange `json:"missing,omitempty"`
+	// Faces lists, for each family, style, weight and format, the fonts needed and the characters they lack
+	Faces []FaceCoverage `json:"faces"`
+}
+
+// FaceCoverage is the fonts of one font face needed to render a text
+type FaceCoverage struct {
+	Family string     `json:"family"`
+	Style  string     `json:"style,omitempty"`
+	Weight AxisValue  `json:"weight"`
+	Format string     `json:"format,omitempty"`
+	Fonts  []Typeface `json:"fonts"`
+	// Missing are the characters of the text that this face lacks
+	Missing UnicodeRange `json:"missing,omitempty"`
+}
+
+// ForText returns, for each family, style, weight and format, the fonts whose unicode ranges cover the characters of
+// text, such as the subset files to preload for a page. Fonts are picked greedily, the one covering the most
+// characters first, so that few files are needed. Control characters are ignored. Characters that no font covers are
+// reported in Coverage.Missing.
+func (ts *Typefaces) ForText(text string) *Coverage {
+	needed := UnicodeRangeOf(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text))
+
+	keys := []string{}
+	groups := map[string][]int{}
+	for i, v := range ts.Fonts {
+		key := v.Family + "/" + v.FontStyle() + "/" + v.Weight.String() + "/" + v.Format
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	result := &Coverage{Fonts: []Typeface{}, Missing: needed, Faces: []FaceCoverage{}}
+	used := make([]bool, len(ts.Fonts))
+	for _, key := range keys {
+		indexes := groups[key]
+		face := ts.Fonts[indexes[0]]
+		fc := FaceCoverage{Family: face.Family, Style: face.FontStyle(), Weight: face.Weight, Format: face.Format,
+			Fonts: []Typeface{}}
+
+		// greedy set cover: take the font covering the most remaining characters until none helps
+		remaining := needed
+		picked := map[int]bool{}
+		for len(remaining) > 0 {
+			best, bestLen := -1, 0
+			for _, i := range indexes {
+				if picked[i] {
+					continue
+				}
+				if n := remaining.Intersect(ts.Fonts[i].Runes()).Len(); n > bestLen {
+					best, bestLen = i, n
+				}
+			}
+			if best == -1 {
+				break
+			}
+			picked[best] = true
+			remaining = remaining.Subtract(ts.Fonts[best].Runes())
+			result.Missing = result.Missing.Subtract(ts.Fonts[best].Runes())
+		}
+
+		for _, i := range indexes {
+			if picked[i] {
+				fc.Fonts = append(fc.Fonts, ts.Fonts[i])
+				used[i] = true
+			}
+		}
+		fc.Missing = remaining
+		result.Faces = append(result.Faces, fc)
+	}
+
+	for i, v := range ts.Fonts {
+		if used[i] {
+			result.Fonts = append(result.Fonts, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,341 @@
+package gfont
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/css/scanner"
+)
+
+// FeatureSetting is an OpenType feature of the font-feature-settings descriptor
+type FeatureSetting struct {
+	Tag   string `json:"tag"`
+	Value int    `json:"value"`
+}
+
+// String returns the CSS representation of a feature setting, such as "liga" 0
+func (fs FeatureSetting) String() string {
+	if fs.Value == 1 {
+		return quote(fs.Tag)
+	}
+	return quote(fs.Tag) + " " + strconv.Itoa(fs.Value)
+}
+
+// parseFeatureSettings parses the value of font-feature-settings
+func parseFeatureSettings(tokens []*scanner.Token) ([]FeatureSetting, error) {
+	if len(tokens) == 1 && tokens[0].Type == scanner.TokenIdent && tokens[0].Value == "normal" {
+		return nil, nil
+	}
+
+	result := []FeatureSetting{}
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].Type != scanner.TokenString {
+			return nil, fmt.Errorf("expect <string> but got %s", tokens[i].String())
+		}
+		setting := FeatureSetting{Tag: unquote(tokens[i].Value), Value: 1}
+		if len(setting.Tag) != 4 {
+			return nil, fmt.Errorf("feature tag %q must have 4 characters", setting.Tag)
+		}
+
+		if i+1 < len(tokens) && !isComma(tokens[i+1]) {
+			i++
+			switch {
+			case tokens[i].Type == scanner.TokenNumber:
+				v, err := strconv.Atoi(tokens[i].Value)
+				if err != nil || v < 0 {
+					return nil, fmt.Errorf("invalid feature value %s", tokens[i].Value)
+				}
+				setting.Value = v
+			case tokens[i].Type == scanner.TokenIdent && tokens[i].Value == "on":
+				setting.Value = 1
+			case tokens[i].Type == scanner.TokenIdent && tokens[i].Value == "off":
+				setting.Value = 0
+			default:
+				return nil, fmt.Errorf("expect <number>, on or off but got %s", tokens[i].String())
+			}
+		}
+		result = append(result, setting)
+
+		if i+1 < len(tokens) {
+			i++
+			if !isComma(tokens[i]) || i+1 == len(tokens) {
+				return nil, fmt.Errorf("expect , between features but got %s", tokens[i].String())
+			}
+		}
+	}
+	return result, nil
+}
+
+// parsePercentage parses a metric override such as 90%. normal returns nil.
+func parsePercentage(tokens []*scanner.Token) (*float64, error) {
+	if len(tokens) != 1 {
+		return nil, fmt.Errorf("expect a single <percentage>")
+	}
+	if tokens[0].Type == scanner.TokenIdent && tokens[0].Value == "normal" {
+		return nil, nil
+	}
+	if tokens[0].Type != scanner.TokenPercentage {
+		return nil, fmt.Errorf("expect <percentage> but got %s", tokens[0].String())
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSuffix(tokens[0].Value, "%"), 64)
+	if err != nil || v < 0 {
+		return nil, fmt.Errorf("invalid percentage %s", tokens[0].Value)
+	}
+	return &v, nil
+}
+
+// stretchKeywords maps the font-stretch keywords to percentages
+var stretchKeywords = map[string]float64{
+	"ultra-condensed": 50,
+	"extra-condensed": 62.5,
+	"condensed":       75,
+	"semi-condensed":  87.5,
+	"normal":          100,
+	"semi-expanded":   112.5,
+	"expanded":        125,
+	"extra-expanded":  150,
+	"ultra-expanded":  200,
+}
+
+// defaultObliqueAngle is the angle of font-style: oblique without an angle, in degrees
+const defaultObliqueAngle = 14
+
+// parseStyle parses the value of font-style: normal, italic, or oblique followed by an optional angle or range of
+// angles.
+func parseStyle(tokens []*scanner.Token) (string, *AxisValue, error) {
+	fields := []string{}
+	sign := ""
+	for _, t := range tokens {
+		// the scanner does not know signed numbers, so -10deg comes as - and 10deg
+		if t.Type == scanner.TokenChar && (t.Value == "-" || t.Value == "+") && sign == "" {
+			sign = t.Value
+			continue
+		}
+		if t.Type != scanner.TokenIdent && t.Type != scanner.TokenDimension && t.Type != scanner.TokenNumber ||
+			(sign != "" && t.Type == scanner.TokenIdent) {
+			return "", nil, fmt.Errorf("unexpected %s", t.String())
+		}
+		fields = append(fields, sign+t.Value)
+		sign = ""
+	}
+	if sign != "" {
+		return "", nil, fmt.Errorf("unexpected %s", sign)
+	}
+	return parseStyleFields(fields)
+}
+
+// parseStyleFields parses a font-style value split into words
+func parseStyleFields(fields []string) (string, *AxisValue, error) {
+	if len(fields) == 0 {
+		return "", nil, fmt.Errorf("expect normal, italic or oblique")
+	}
+
+	switch fields[0] {
+	case "normal", "italic":
+		if len(fields) > 1 {
+			return "", nil, fmt.Errorf("%s does not take an angle", fields[0])
+		}
+		return fields[0], nil, nil
+	case "oblique":
+		if len(fields) == 1 {
+			return fields[0], nil, nil
+		}
+		if len(fields) > 3 {
+			return "", nil, fmt.Errorf("expect one angle or a range of two angles")
+		}
+
+		min, err := parseAngle(fields[1])
+		if err != nil {
+			return "", nil, err
+		}
+		max := min
+		if len(fields) == 3 {
+			if max, err = parseAngle(fields[2]); err != nil {
+				return "", nil, err
+			}
+		}
+		if min > max {
+			min, max = max, min
+		}
+		slant := AxisRange(min, max)
+		return fields[0], &slant, nil
+	default:
+		return "", nil, fmt.Errorf("expect normal, italic or oblique but got %s", fields[0])
+	}
+}
+
+// parseAngle parses a CSS angle between -90deg and 90deg, and returns it in degrees
+func parseAngle(s string) (float64, error) {
+	units := map[string]float64{"deg": 1, "grad": 0.9, "rad": 180 / math.Pi, "turn": 360}
+
+	for unit, factor := range units {
+		if !strings.HasSuffix(s, unit) || (unit == "rad" && strings.HasSuffix(s, "grad")) {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSuffix(s, unit), 64)
+		if err != nil {
+			break
+		}
+		v = v * factor
+		if v < -90 || v > 90 {
+			return 0, fmt.Errorf("angle %s out of range -90deg..90deg", s)
+		}
+		return v, nil
+	}
+	if s == "0" {
+		return 0, nil
+	}
+	return 0, fmt.Errorf("invalid angle %s", s)
+}
+
+// parseWeight parses the value of font-weight, which is a weight or a range of weights for a variable font. auto
+// returns the zero value.
+func parseWeight(tokens []*scanner.Token) (AxisValue, error) {
+	if len(tokens) == 1 && tokens[0].Type == scanner.TokenIdent && tokens[0].Value == "auto" {
+		return AxisValue{}, nil
+	}
+
+	return parseRange(tokens, func(t *scanner.Token) (float64, error) {
+		switch {
+		case t.Type == scanner.TokenIdent && t.Value == "normal":
+			return 400, nil
+		case t.Type == scanner.TokenIdent && t.Value == "bold":
+			return 700, nil
+		case t.Type != scanner.TokenNumber:
+			return 0, fmt.Errorf("expect <number> but got %s", t.String())
+		}
+
+		v, err := strconv.ParseFloat(t.Value, 64)
+		if err != nil || v < 1 || v > 1000 {
+			return 0, fmt.Errorf("invalid weight %s", t.Value)
+		}
+		return v, nil
+	})
+}
+
+// parseStretch parses the value of font-stretch, which is a width or a range of widths in percent. auto returns nil.
+func parseStretch(tokens []*scanner.Token) (*AxisValue, error) {
+	if len(tokens) == 1 && tokens[0].Type == scanner.TokenIdent && tokens[0].Value == "auto" {
+		return nil, nil
+	}
+
+	v, err := parseRange(tokens, func(t *scanner.Token) (float64, error) {
+		if t.Type == scanner.TokenIdent {
+			if pct, ok := stretchKeywords[t.Value]; ok {
+				return pct, nil
+			}
+		}
+		if t.Type != scanner.TokenPercentage {
+			return 0, fmt.Errorf("expect <percentage> but got %s", t.String())
+		}
+
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(t.Value, "%"), 64)
+		if err != nil || pct <= 0 {
+			return 0, fmt.Errorf("invalid percentage %s", t.Value)
+		}
+		return pct, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// parseRange parses one or two values into an AxisValue. A reversed range is swapped, as browsers do.
+func parseRange(tokens []*scanner.Token, parse func(*scanner.Token) (float64, error)) (AxisValue, error) {
+	if len(tokens) != 1 && len(tokens) != 2 {
+		return AxisValue{}, fmt.Errorf("expect one value or a range of two values")
+	}
+
+	min, err := parse(tokens[0])
+	if err != nil {
+		return AxisValue{}, err
+	}
+	max := min
+	if len(tokens) == 2 {
+		if max, err = parse(tokens[1]); err != nil {
+			return AxisValue{}, err
+		}
+	}
+	if min > max {
+		min, max = max, min
+	}
+	return AxisRange(min, max), nil
+}
+
+// formatRange returns the CSS representation of v, such as 100 900 or 75% 125%
+func formatRange(v AxisValue, unit string) string {
+	if !v.IsRange() {
+		return formatAxisNumber(v.Min) + unit
+	}
+	return formatAxisNumber(v.Min) + unit + " " + formatAxisNumber(v.Max) + unit
+}
+
+func formatPercentage(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64) + "%"
+}
+
+// readValue returns the tokens of a declaration value, up to the end of the declaration. Whitespace and comments are
+// dropped.
+func readValue(s *tokenReader, lastGoodToken string) ([]*scanner.Token, error) {
+	result := []*scanner.Token{}
+	depth := 0
+	for {
+		token := nextSig(s)
+		if token.Type == scanner.TokenEOF || token.Type == scanner.TokenError {
+			return nil, fmt.Errorf("unexpected EOF after %s", lastGoodToken)
+		}
+		if token.Type == scanner.TokenChar && (token.Value == "}" || (depth == 0 && token.Value == ";")) {
+			// like browsers, close the blocks left open at the end of the rule
+			for ; depth > 0; depth-- {
+				result = append(result, &scanner.Token{Type: scanner.TokenChar, Value: ")"})
+			}
+			return result, endDeclaration(s, token, lastGoodToken)
+		}
+
+		if token.Type == scanner.TokenFunction || (token.Type == scanner.TokenChar && token.Value == "(") {
+			depth++
+		} else if token.Type == scanner.TokenChar && token.Value == ")" && depth > 0 {
+			depth--
+		}
+		result = append(result, token)
+		lastGoodToken = token.String()
+	}
+}
+
+// rawValue renders the tokens of a declaration value
+func rawValue(tokens []*scanner.Token) string {
+	var sb strings.Builder
+	for i, t := range tokens {
+		noSpace := i == 0 || isComma(t) || (t.Type == scanner.TokenChar && t.Value == ")") ||
+			tokens[i-1].Type == scanner.TokenFunction || (tokens[i-1].Type == scanner.TokenChar && tokens[i-1].Value == "(")
+		if !noSpace {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(t.Value)
+	}
+	return sb.String()
+}
+
+func isComma(t *scanner.Token) bool {
+	return t.Type == scanner.TokenChar && t.Value == ","
+}
+
+// otherDeclarations returns the declarations of unknown descriptors, sorted by name
+func otherDeclarations(descriptors map[string]string) [][2]string {
+	names := []string{}
+	for k := range descriptors {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	result := [][2]string{}
+	for _, k := range names {
+		result = append(result, [2]string{k, descriptors[k]})
+	}
+	return result
+}
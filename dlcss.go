@@ -1,11 +1,9 @@
 package gfont
 
 import (
+	"context"
 	"fmt"
-	"strings"
-	"net/http"
 	"net/url"
-	"io/ioutil"
 )
 
 const (
@@ -43,6 +41,42 @@ const (
 	EOT
 )
 
+// CompatProfiles is one profile for each font format Google serves
+var CompatProfiles = []FontProfile{WOFF2, WOFF, TTF, SVG, EOT}
+
+var profileNames = map[FontProfile]string{
+	WOFF2:            "woff2",
+	AppleWOFF2:       "apple_woff2",
+	LegacyWOFF2:      "legacy_woff2",
+	AppleLegacyWOFF2: "apple_legacy_woff2",
+	WOFF:             "woff",
+	AppleWOFF:        "apple_woff",
+	LegacyWOFF:       "legacy_woff",
+	AppleLegacyWOFF:  "apple_legacy_woff",
+	TTF:              "ttf",
+	AppleTTF:         "apple_ttf",
+	SVG:              "svg",
+	EOT:              "eot",
+}
+
+// String returns the profile name, such as apple_woff2
+func (fp FontProfile) String() string {
+	if v, ok := profileNames[fp]; ok {
+		return v
+	}
+	return fmt.Sprintf("FontProfile(%d)", int(fp))
+}
+
+// ParseFontProfile returns the profile for a name returned by FontProfile.String
+func ParseFontProfile(name string) (FontProfile, error) {
+	for k, v := range profileNames {
+		if v == name {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported font profile %s", name)
+}
+
 var useragent = map[FontProfile]string{
 	WOFF2:            "Mozilla/5.0 (Windows NT 6.2; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/51.0.2704.106 Safari/537.36",
     AppleWOFF2:       "Mozilla/5.0 (Macintosh; U; Intel Mac OS X 10.10; rv:62.0) Gecko/20100101 Firefox/62.0",
@@ -58,48 +92,14 @@ var useragent = map[FontProfile]string{
     EOT:              "MSIE 8.0",
 }
 
-// GetURL returns the URL for font-face CSS from Google API
+// GetURL returns the URL for font-face CSS from Google API. It is a shortcut for Client.GetURL.
 func GetURL(ua FontProfile, fontFamily, fontStyle string, mirror *url.URL) string {
-	_, ok := useragent[ua]
-	if !ok {
-		return ""
-	}
-
-	apiURL := apiBaseURL
-	if mirror != nil {
-		apiURL = mirror.String()
-	}
-	return fmt.Sprintf("%s?family=%s:%s", apiURL, strings.Replace(fontFamily, " ", "+", -1), fontStyle)
+	c := &Client{BaseURL: mirror}
+	return c.GetURL(ua, fontFamily, fontStyle)
 }
 
-// DownloadCSS downloads font-face CSS from Google API
+// DownloadCSS downloads font-face CSS from Google API. It is a shortcut for Client.DownloadCSS.
 func DownloadCSS(ua FontProfile, fontFamily, fontStyle string, mirror *url.URL) ([]byte, error) {
-	uaString, ok := useragent[ua]
-	if !ok {
-		return nil, fmt.Errorf("ua unsupported")
-	}
-
-	apiURL := apiBaseURL
-	if mirror != nil {
-		apiURL = mirror.String()
-	}
-
-	cssURL := fmt.Sprintf("%s?family=%s:%s", apiURL, strings.Replace(fontFamily, " ", "+", -1), fontStyle)
-	req, errReq := http.NewRequest("GET", cssURL, nil)
-	if errReq != nil {
-		return nil, errReq
-	}
-	req.Header.Set("user-agent", uaString)
-	response, err := http.DefaultClient.Do(req)
-	if err != nil { 
-		return nil, err
-	}
-
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return nil, err
-	}
-	_ = response.Body.Close()
-
-	return body, nil
+	c := &Client{BaseURL: mirror}
+	return c.DownloadCSS(context.Background(), ua, fontFamily, fontStyle)
 }
\ No newline at end of file
@@ -0,0 +1,185 @@
+package gfont
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Downloader downloads the font files referenced by Typefaces
+type Downloader struct {
+	// Client sends the requests, using its HTTP client, retry policy, limiter and concurrency limit.
+	// A zero Client is used if nil.
+	Client *Client
+	// Dir is the root directory. Files are written to Dir/<family>/<version>/<filename>.
+	Dir string
+	// Overwrite downloads files even if they are already present
+	Overwrite bool
+}
+
+// ManifestEntry describes a downloaded font file
+type ManifestEntry struct {
+	Family string    `json:"family"`
+	Style  string    `json:"style"`
+	Weight AxisValue `json:"weight"`
+	Format string    `json:"format"`
+	URL    string    `json:"url"`
+	// Path is relative to the download directory, with forward slashes
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Skipped is true if the file was already present
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// Manifest lists the files written by a Downloader
+type Manifest struct {
+	Dir   string          `json:"dir"`
+	Files []ManifestEntry `json:"files"`
+}
+
+// NewDownloader returns a Downloader writing to dir
+func NewDownloader(dir string) *Downloader {
+	return &Downloader{Dir: dir}
+}
+
+// Path returns the path of the font file for t, relative to the download directory and with forward slashes. The
+// family, version and file name come from the CSS and the URL, so each is reduced to letters, digits, - and _ to keep
+// the file in the download directory.
+func (d *Downloader) Path(t *Typeface) (string, error) {
+	fileName := t.FileName()
+	ext := path.Ext(fileName)
+	fileName = pathSlug(strings.TrimSuffix(fileName, ext), true)
+	if fileName == "" {
+		return "", fmt.Errorf("%s: cannot infer file name from url", t.String())
+	}
+	if ext = pathSlug(strings.TrimPrefix(ext, "."), false); ext != "" {
+		fileName = fileName + "." + ext
+	}
+
+	family := pathSlug(strings.Replace(t.Family, " ", "", -1), false)
+	if family == "" {
+		family = "unknown"
+	}
+	return path.Join(family, pathSlug(t.Version(), false), fileName), nil
+}
+
+// fullPath joins relPath onto the download directory, and fails if the result is outside of it
+func (d *Downloader) fullPath(relPath string) (string, error) {
+	fullPath := filepath.Join(d.Dir, filepath.FromSlash(relPath))
+	rel, err := filepath.Rel(d.Dir, fullPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: path is outside of %s", relPath, d.Dir)
+	}
+	return fullPath, nil
+}
+
+// Download fetches every font file in ts concurrently, including every source of each font. Files already present are skipped unless Overwrite is set.
+// The manifest lists each file once, in the order of ts.
+func (d *Downloader) Download(ctx context.Context, ts *Typefaces) (*Manifest, error) {
+	client := d.Client
+	if client == nil {
+		client = &Client{}
+	}
+
+	entries := []ManifestEntry{}
+	seen := map[string]bool{}
+	for _, face := range ts.Fonts {
+		for _, src := range face.AllSources() {
+			if src.IsLocal() {
+				continue
+			}
+
+			t := face.withSource(src)
+			relPath, err := d.Path(&t)
+			if err != nil {
+				return nil, err
+			}
+			if seen[relPath] {
+				continue
+			}
+			seen[relPath] = true
+
+			entries = append(entries, ManifestEntry{
+				Family: t.Family,
+				Style:  t.FontStyle(),
+				Weight: t.Weight,
+				Format: t.Format,
+				URL:    t.URL.String(),
+				Path:   relPath,
+			})
+		}
+	}
+
+	err := client.forEach(ctx, len(entries), func(ctx context.Context, i int) error {
+		if err := d.fetch(ctx, client, &entries[i]); err != nil {
+			return fmt.Errorf("%s: %w", entries[i].URL, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manifest{Dir: d.Dir, Files: entries}, nil
+}
+
+func (d *Downloader) fetch(ctx context.Context, client *Client, e *ManifestEntry) error {
+	fullPath, err := d.fullPath(e.Path)
+	if err != nil {
+		return err
+	}
+	if !d.Overwrite {
+		content, err := ioutil.ReadFile(fullPath)
+		if err == nil {
+			e.Skipped = true
+			e.Size = int64(len(content))
+			e.SHA256 = sha256Hex(content)
+			return nil
+		}
+	}
+
+	body, err := client.fetchFile(ctx, e.URL)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(fullPath, body); err != nil {
+		return err
+	}
+
+	e.Size = int64(len(body))
+	e.SHA256 = sha256Hex(body)
+	return nil
+}
+
+// pathSlug replaces each character of s other than a-z, 0-9 and - with -. Uppercase letters and _ are kept if
+// keepCase is set, as in the case sensitive file ids of gstatic; otherwise uppercase letters are lowercased.
+func pathSlug(s string, keepCase bool) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-':
+			return r
+		case keepCase && ((r >= 'A' && r <= 'Z') || r == '_'):
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '-'
+		}
+	}, s)
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,130 @@
+package gfont
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newFontServer returns a test server answering every request with the request path as body
+func newFontServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI()))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fontFaces returns the typefaces of family, one for each path on srv
+func fontFaces(t *testing.T, srv *httptest.Server, family string, paths ...string) *Typefaces {
+	t.Helper()
+	css := ""
+	for _, p := range paths {
+		css += "@font-face {\n  font-family: '" + family + "';\n  font-style: normal;\n  font-weight: 400;\n  src: url(" +
+			srv.URL + p + ") format('woff2');\n}\n"
+	}
+	ts := &Typefaces{}
+	if err := UnmarshalCSS([]byte(css), ts); err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestDownloaderPath(t *testing.T) {
+	srv := newFontServer(t)
+	d := NewDownloader(t.TempDir())
+	ts := fontFaces(t, srv, "Open Sans",
+		"/s/opensans/v18/mem8YaGs126MiZpBA-UFVZ0b.woff2",
+		"/s/opensans/v20/mem8YaGs126MiZpBA_UFW.TTF")
+
+	want := []string{"opensans/v18/mem8YaGs126MiZpBA-UFVZ0b.woff2", "opensans/v20/mem8YaGs126MiZpBA_UFW.ttf"}
+	for i := range ts.Fonts {
+		got, err := d.Path(&ts.Fonts[i])
+		if err != nil || got != want[i] {
+			t.Errorf("got %s, %v, want %s", got, err, want[i])
+		}
+	}
+}
+
+func TestDownloaderMaliciousPath(t *testing.T) {
+	srv := newFontServer(t)
+	root := t.TempDir()
+	d := NewDownloader(filepath.Join(root, "fonts"))
+	ts := fontFaces(t, srv, "../../etc",
+		"/s/x/v1/..%2F..%2Fpasswd.woff2",
+		"/fonts/v1/..%5C..%5Cpasswd",
+		"/l/font?kit=../../../shadow&v=../..")
+
+	manifest, err := d.Download(context.Background(), ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range manifest.Files {
+		if strings.Contains(f.Path, "..") || strings.Contains(f.Path, `\`) || !strings.HasPrefix(f.Path, "------etc/") {
+			t.Errorf("unsafe path %s", f.Path)
+		}
+	}
+
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && !strings.HasPrefix(p, d.Dir+string(filepath.Separator)) {
+			t.Errorf("file written outside of the download directory: %s", p)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.fullPath("../escape.woff2"); err == nil {
+		t.Errorf("expect error for a path outside of the download directory")
+	}
+}
+
+func TestDownloaderSkipExisting(t *testing.T) {
+	srv := newFontServer(t)
+	d := NewDownloader(t.TempDir())
+	d.Client = &Client{HTTPClient: srv.Client()}
+	ts := fontFaces(t, srv, "Domine", "/s/domine/v20/a.woff2", "/s/domine/v20/b.woff2", "/s/domine/v20/a.woff2")
+
+	existing := filepath.Join(d.Dir, "domine", "v20", "a.woff2")
+	if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(existing, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := d.Download(context.Background(), ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 2 {
+		t.Fatalf("got %d files, want 2", len(manifest.Files))
+	}
+	a, b := manifest.Files[0], manifest.Files[1]
+	if !a.Skipped || a.Size != 3 || a.SHA256 != sha256Hex([]byte("old")) {
+		t.Errorf("existing file: got %+v", a)
+	}
+	if b.Skipped || b.Path != "domine/v20/b.woff2" || b.SHA256 != sha256Hex([]byte("/s/domine/v20/b.woff2")) {
+		t.Errorf("new file: got %+v", b)
+	}
+
+	d.Overwrite = true
+	manifest, err = d.Download(context.Background(), ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Files[0].Skipped || string(content) != "/s/domine/v20/a.woff2" {
+		t.Errorf("got skipped %v, content %q", manifest.Files[0].Skipped, content)
+	}
+}
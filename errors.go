@@ -0,0 +1,111 @@
+package gfont
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnknownFamily is returned when the API does not know the requested font family
+	ErrUnknownFamily = errors.New("font family not found")
+	// ErrInvalidAxis is returned when the API rejects the requested axis spec
+	ErrInvalidAxis = errors.New("invalid axis spec")
+	// ErrRateLimited is returned when the API throttles the client
+	ErrRateLimited = errors.New("rate limited")
+	// ErrServer is returned when the API fails with a 5xx status
+	ErrServer = errors.New("server error")
+	// ErrHTTPStatus is returned for any other non-2xx status
+	ErrHTTPStatus = errors.New("unexpected http status")
+)
+
+// maxExcerpt is the maximum number of bytes of response body kept in APIError
+const maxExcerpt = 512
+
+// APIError is returned when the API responds with a non-2xx status.
+// Use errors.Is with ErrUnknownFamily, ErrInvalidAxis, ErrRateLimited, ErrServer or ErrHTTPStatus to classify it.
+type APIError struct {
+	// Kind is one of the sentinel errors in this package
+	Kind error
+	// StatusCode is the HTTP status code
+	StatusCode int
+	// URL is the requested URL
+	URL string
+	// Body is an excerpt of the response body
+	Body string
+	// RetryAfter is the delay asked for by the Retry-After header, if any
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%v: %d %s (GET %s)", e.Kind, e.StatusCode, http.StatusText(e.StatusCode), e.URL)
+	if e.Body != "" {
+		msg = msg + ": " + e.Body
+	}
+	return msg
+}
+
+// Unwrap returns the error kind
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+func newAPIError(statusCode int, rawURL string, header http.Header, body []byte) *APIError {
+	excerpt := bodyExcerpt(body)
+	return &APIError{
+		Kind:       classifyStatus(statusCode, excerpt),
+		StatusCode: statusCode,
+		URL:        rawURL,
+		Body:       excerpt,
+		RetryAfter: parseRetryAfter(header.Get("Retry-After")),
+	}
+}
+
+func classifyStatus(statusCode int, body string) error {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode >= 500:
+		return ErrServer
+	case statusCode == http.StatusBadRequest:
+		// css2 answers 400 both for unknown families and for malformed axis specs
+		lbody := strings.ToLower(body)
+		if strings.Contains(lbody, "family") && strings.Contains(lbody, "not found") {
+			return ErrUnknownFamily
+		}
+		return ErrInvalidAxis
+	default:
+		return ErrHTTPStatus
+	}
+}
+
+// bodyExcerpt returns the response body with markup and extra whitespace removed, truncated to maxExcerpt bytes
+func bodyExcerpt(body []byte) string {
+	var sb strings.Builder
+	inTag := false
+	for _, r := range string(body) {
+		switch {
+		case r == '<':
+			inTag = true
+			sb.WriteRune(' ')
+		case r == '>':
+			inTag = false
+		case !inTag:
+			sb.WriteRune(r)
+		}
+	}
+
+	excerpt := strings.Join(strings.Fields(sb.String()), " ")
+	if len(excerpt) <= maxExcerpt {
+		return excerpt
+	}
+
+	excerpt = excerpt[:maxExcerpt]
+	for !utf8.ValidString(excerpt) {
+		excerpt = excerpt[:len(excerpt)-1]
+	}
+	return excerpt + "..."
+}
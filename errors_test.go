@@ -0,0 +1,74 @@
+package gfont
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIErrorKind(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusBadRequest, "<html><body>Font family not found: Nope</body></html>", ErrUnknownFamily},
+		{http.StatusBadRequest, "Invalid selector: wght@2000", ErrInvalidAxis},
+		{http.StatusTooManyRequests, "", ErrRateLimited},
+		{http.StatusInternalServerError, "", ErrServer},
+		{http.StatusServiceUnavailable, "", ErrServer},
+		{http.StatusForbidden, "", ErrHTTPStatus},
+		{http.StatusNotFound, "family not found", ErrHTTPStatus},
+	}
+
+	for _, tt := range tests {
+		c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		})
+
+		_, err := c.DownloadCSS(context.Background(), TTF, "Nope", "")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Errorf("%d %q: got %v, want *APIError", tt.status, tt.body, err)
+			continue
+		}
+		if !errors.Is(err, tt.want) || apiErr.StatusCode != tt.status {
+			t.Errorf("%d %q: got %v %d, want %v", tt.status, tt.body, apiErr.Kind, apiErr.StatusCode, tt.want)
+		}
+		if !strings.HasPrefix(apiErr.URL, c.baseURL()+"?family=Nope") {
+			t.Errorf("got url %s", apiErr.URL)
+		}
+	}
+}
+
+func TestAPIErrorRetryAfter(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	_, err := c.DownloadCSS(context.Background(), TTF, "Domine", "")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 7*time.Second {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestBodyExcerpt(t *testing.T) {
+	got := bodyExcerpt([]byte("<html>\n<head><title>Error 400</title></head>\n<body>  Font family\n not found </body></html>"))
+	if got != "Error 400 Font family not found" {
+		t.Errorf("got %q", got)
+	}
+
+	long := bodyExcerpt([]byte(strings.Repeat("é", maxExcerpt)))
+	if !strings.HasSuffix(long, "...") || len(long) > maxExcerpt+len("...") {
+		t.Errorf("got %d bytes %q", len(long), long)
+	}
+	if trimmed := strings.TrimSuffix(long, "..."); strings.ContainsRune(trimmed, '�') || len(trimmed)%2 != 0 {
+		t.Errorf("excerpt cuts a character: %q", trimmed)
+	}
+}
@@ -0,0 +1,144 @@
+package gfont
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// URLKind is the kind of font file URL
+type URLKind int
+
+const (
+	// CustomURL is a URL that does not follow the gstatic layout, such as a self-hosted file
+	CustomURL URLKind = iota
+	// StaticURL is a static file, such as /s/domine/v10/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1g.woff2
+	StaticURL
+	// KitURL is a generated file, such as a text subset: /l/font?kit=L0xhDFMnlVwD4h3L&skey=ea73fc1e1d1dfd9a&v=v10
+	KitURL
+	// SVGURL is a kit URL of an SVG font. The fragment is the id of the font in the SVG document.
+	SVGURL
+)
+
+var urlKindNames = map[URLKind]string{
+	CustomURL: "custom",
+	StaticURL: "static",
+	KitURL:    "kit",
+	SVGURL:    "svg",
+}
+
+// String returns the name of the kind, such as static
+func (k URLKind) String() string {
+	return urlKindNames[k]
+}
+
+// MarshalText returns the name of the kind
+func (k URLKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+const gstaticHost = "fonts.gstatic.com"
+
+var versionPattern = regexp.MustCompile(`^v[0-9]+$`)
+
+// FontURL is a font file URL broken down into its parts. Fields that do not apply to the kind of URL are empty.
+type FontURL struct {
+	Kind URLKind `json:"kind"`
+	// Mirror is true for a gstatic layout served by another host
+	Mirror bool   `json:"mirror,omitempty"`
+	Host   string `json:"host,omitempty"`
+	// FamilySlug is the family in the path of static URLs, such as domine
+	FamilySlug string `json:"familySlug,omitempty"`
+	// Version is the font version, such as v10
+	Version string `json:"version,omitempty"`
+	// FileID is the file name without extension for static and custom URLs, and the kit for kit URLs
+	FileID string `json:"fileId,omitempty"`
+	// Ext is the file extension. For kit URLs, it is inferred from the format.
+	Ext string `json:"ext,omitempty"`
+	// Skey is the skey parameter of kit URLs
+	Skey string `json:"skey,omitempty"`
+	// FontID is the id of the font in an SVG document
+	FontID string `json:"fontId,omitempty"`
+}
+
+// ParseFontURL breaks down a font file URL. format is the format hint of the URL, such as woff2. It is used for
+// kit URLs, whose path does not tell the file type.
+func ParseFontURL(rawURL, format string) (*FontURL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseFontURL(u, format), nil
+}
+
+// FileName returns a file name for the font, such as L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1g.woff2
+func (fu *FontURL) FileName() string {
+	if fu.FileID == "" || fu.Ext == "" {
+		return fu.FileID
+	}
+	return fu.FileID + "." + fu.Ext
+}
+
+// parseFontURL classifies u. It never fails: a URL it does not understand is a CustomURL.
+func parseFontURL(u *url.URL, format string) *FontURL {
+	result := &FontURL{}
+	if u == nil {
+		return result
+	}
+	result.Host = u.Host
+
+	segments := []string{}
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	n := len(segments)
+
+	switch {
+	case n >= 2 && segments[n-2] == "l" && segments[n-1] == "font" && u.Query().Get("kit") != "":
+		// /l/font?kit=L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI18&skey=ea73fc1e1d1dfd9a&v=v10#Domine
+		query := u.Query()
+		result.Kind = KitURL
+		result.FileID = query.Get("kit")
+		result.Skey = query.Get("skey")
+		result.Version = query.Get("v")
+		result.Ext = fontExtensions[format]
+		if format == "svg" || (format == "" && u.Fragment != "") {
+			result.Kind = SVGURL
+			result.FontID = u.Fragment
+			result.Ext = "svg"
+		}
+	case n >= 4 && segments[n-4] == "s" && versionPattern.MatchString(segments[n-2]):
+		// /s/domine/v10/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1g.woff2
+		result.Kind = StaticURL
+		result.FamilySlug = segments[n-3]
+		result.Version = segments[n-2]
+		result.FileID, result.Ext = splitExt(segments[n-1], format)
+	default:
+		result.Kind = CustomURL
+		if n == 0 || strings.HasSuffix(u.Path, "/") {
+			return result
+		}
+		if n >= 2 && versionPattern.MatchString(segments[n-2]) {
+			result.Version = segments[n-2]
+		}
+		result.FileID, result.Ext = splitExt(segments[n-1], format)
+	}
+
+	result.Mirror = result.Kind != CustomURL && u.Host != gstaticHost
+	return result
+}
+
+// splitExt splits a file name into name and extension. The extension of format is used if the name has none.
+func splitExt(name, format string) (string, string) {
+	ext := path.Ext(name)
+	if ext == name {
+		return name, ""
+	}
+	if ext == "" {
+		return name, fontExtensions[format]
+	}
+	return strings.TrimSuffix(name, ext), ext[1:]
+}
@@ -0,0 +1,67 @@
+package gfont
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseFontURL(t *testing.T) {
+	tests := []struct {
+		url    string
+		format string
+		want   FontURL
+	}{
+		{
+			"https://fonts.gstatic.com/s/domine/v10/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1g.woff2", "woff2",
+			FontURL{Kind: StaticURL, Host: "fonts.gstatic.com", FamilySlug: "domine", Version: "v10",
+				FileID: "L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1g", Ext: "woff2"},
+		},
+		{
+			"https://fonts.gstatic.com/l/font?kit=L0xhDFMnlVwD4h3L&skey=ea73fc1e1d1dfd9a&v=v10", "truetype",
+			FontURL{Kind: KitURL, Host: "fonts.gstatic.com", Version: "v10", FileID: "L0xhDFMnlVwD4h3L", Ext: "ttf",
+				Skey: "ea73fc1e1d1dfd9a"},
+		},
+		{
+			"https://fonts.gstatic.com/l/font?kit=L0xhDFMnlVwD4h3L&skey=ea73fc1e1d1dfd9a&v=v10#Domine", "svg",
+			FontURL{Kind: SVGURL, Host: "fonts.gstatic.com", Version: "v10", FileID: "L0xhDFMnlVwD4h3L", Ext: "svg",
+				Skey: "ea73fc1e1d1dfd9a", FontID: "Domine"},
+		},
+		{
+			"https://mirror.example.com/gstatic/s/domine/v10/abc.ttf", "",
+			FontURL{Kind: StaticURL, Mirror: true, Host: "mirror.example.com", FamilySlug: "domine", Version: "v10",
+				FileID: "abc", Ext: "ttf"},
+		},
+		{
+			"/static/fonts/domine/v10/abc", "woff",
+			FontURL{Kind: CustomURL, Version: "v10", FileID: "abc", Ext: "woff"},
+		},
+		{"x.eot", "eot", FontURL{Kind: CustomURL, FileID: "x", Ext: "eot"}},
+		{"https://example.com/", "woff2", FontURL{Kind: CustomURL, Host: "example.com"}},
+		{"https://example.com/l/font", "woff2", FontURL{Kind: CustomURL, Host: "example.com", FileID: "font", Ext: "woff2"}},
+		{"", "", FontURL{Kind: CustomURL}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFontURL(tt.url, tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != tt.want {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", tt.url, *got, tt.want)
+		}
+	}
+}
+
+func TestTypefaceFileNameNeverPanics(t *testing.T) {
+	for _, raw := range []string{"", "/", "x", "v1/", "?", "#", "?kit=", "/l/font?kit=a", "s/v1/x", "//host"} {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tf := Typeface{URL: u}
+		_ = tf.FileName() + tf.Version()
+	}
+	if got := (&Typeface{}).FileName(); got != "" {
+		t.Errorf("got %q for a nil URL", got)
+	}
+}
@@ -1,29 +1,52 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/url"
 	"fmt"
 	"flag"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 	"io/ioutil"
 	"encoding/json"
 
 	"github.com/imacks/gfont"
+	"github.com/imacks/gfont/sfnt"
 )
 
 var (
 	cmdlet string
 	infile string
 	outfile string
-	fontFamily string
-	fontStyle string
+	fontFamily stringList
+	fontStyle stringList
 	fontProfile string
+	lockProfiles string
 	filterField string
 	mirrorProxy string
+	cacheDir string
+	cacheTTL time.Duration
+	offline bool
+	maxRetries int
+	rateLimit float64
+	outdir string
+	lockFile string
+	baseURL string
+	subsets string
+	text string
+	textFile string
+	overwrite bool
 	pretty bool
 	verbose bool
 	compatMode bool
+	verifyFont bool
+	fontFormat string
+	unicodeRange string
+	splitFile string
+	dropLayout bool
 )
 
 const (
@@ -48,16 +71,23 @@ var fpArgmap = map[string]gfont.FontProfile{
 func init() {
 	dlFlagSet := flag.NewFlagSet("download", flag.ExitOnError)
 	dlFlagSet.StringVar(&outfile, "o", "-", "Output to file or stdout")
-	dlFlagSet.StringVar(&fontFamily, "t", "", "Font name (mandatory)")
-	dlFlagSet.StringVar(&fontStyle, "s", "", "Font style params")
+	dlFlagSet.Var(&fontFamily, "t", "Font name (mandatory, repeatable)")
+	dlFlagSet.Var(&fontStyle, "s", "Font style params for the matching -t (repeatable)")
 	dlFlagSet.StringVar(&fontProfile, "p", "woff2", "Font profile (see notes)")
 	dlFlagSet.StringVar(&mirrorProxy, "m", "", "Mirror proxy")
+	dlFlagSet.StringVar(&cacheDir, "cache-dir", "", "Cache responses in this directory")
+	dlFlagSet.DurationVar(&cacheTTL, "cache-ttl", 24*time.Hour, "Serve cached responses younger than this without revalidation")
+	dlFlagSet.BoolVar(&offline, "offline", false, "Serve only from cache (requires --cache-dir)")
+	dlFlagSet.IntVar(&maxRetries, "retries", 3, "Retry rate limited and failed requests up to this many times")
+	dlFlagSet.Float64Var(&rateLimit, "rps", 0, "Max requests per second (0 = unlimited)")
+	dlFlagSet.StringVar(&text, "text", "", "Only request the characters of this text")
+	dlFlagSet.StringVar(&textFile, "text-file", "", "Only request the characters of the text in this file")
 	dlFlagSet.BoolVar(&verbose, "v", false, "Verbose mode")
 	dlFlagSet.Usage = func() {
 		fmt.Fprintf(os.Stdout, "%s %s () %s\n", appName, appVer, appDesc)
 		fmt.Fprintf(os.Stdout, "download font-face CSS from Google API\n")
 		fmt.Fprintf(os.Stdout, "\n")
-		fmt.Fprintf(os.Stdout, "Usage: %s download -t <family> -s <style> [-p <profile>] [-o <file.css>] [-m <url>] [-v]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "Usage: %s download -t <family> -s <style> [-t <family> -s <style>...] [-p <profile>] [-o <file.css>] [-m <url>] [--text <text> | --text-file <file.txt>] [--cache-dir <dir> [--cache-ttl <duration>] [--offline]] [-v]\n", os.Args[0])
 		fmt.Fprintf(os.Stdout, "\n")
 		dlFlagSet.PrintDefaults()
 		fmt.Fprintf(os.Stdout, "\n")
@@ -66,9 +96,17 @@ func init() {
 		fmt.Fprintf(os.Stdout, "    woff  | apple_woff  | legacy_woff\n")
 		fmt.Fprintf(os.Stdout, "    ttf   | apple_ttf\n")
 		fmt.Fprintf(os.Stdout, "    svg   | eot\n")
+		fmt.Fprintf(os.Stdout, "    all   (woff2, woff, ttf, svg and eot in parallel, output is fonts data in JSON format)\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Exit codes:\n")
+		fmt.Fprintf(os.Stdout, "    1 = other error | 2 = unknown family or invalid style | 3 = rate limited or server error\n")
 		fmt.Fprintf(os.Stdout, "\n")
 		fmt.Fprintf(os.Stdout, "Example:\n")
 		fmt.Fprintf(os.Stdout, "    %s download -t Domine -s 'wght@400;500;600;700' -p woff2 -o font.css\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "    %s download -t Domine -s 'wght@400;700' -t 'Open Sans' -s 'ital,wght@0,400;1,400' -o fonts.css\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "    %s download -t Domine -s 'wght@400;700' -p all -o fonts.json\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "    %s download -t Domine -s 'wght@400;700' --cache-dir .gfont-cache --offline\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "    %s download -t Domine -s 'wght@700' --text 'Hello, 世界' -o logo.css\n", os.Args[0])
 		fmt.Fprintf(os.Stdout, "\n")
 	}
 
@@ -100,7 +138,7 @@ func init() {
 		filterFlagSet.PrintDefaults()
 		fmt.Fprintf(os.Stdout, "\n")
 		fmt.Fprintf(os.Stdout, "Supported query fields:\n")
-		fmt.Fprintf(os.Stdout, "    url | family | format\n")
+		fmt.Fprintf(os.Stdout, "    url | family | format | subset\n")
 		fmt.Fprintf(os.Stdout, "\n")
 		fmt.Fprintf(os.Stdout, "Example:\n")
 		fmt.Fprintf(os.Stdout, "    %s filter -i font.json -q url\n", os.Args[0])
@@ -140,15 +178,228 @@ func init() {
 		fmt.Fprintf(os.Stdout, "\n")
 	}
 
+	fetchFlagSet := flag.NewFlagSet("fetch", flag.ExitOnError)
+	fetchFlagSet.StringVar(&infile, "i", "", "Input file (mandatory)")
+	fetchFlagSet.StringVar(&outdir, "d", "", "Output directory (mandatory)")
+	fetchFlagSet.StringVar(&outfile, "o", "-", "Output manifest to file or stdout")
+	fetchFlagSet.BoolVar(&overwrite, "f", false, "Download files already present again")
+	fetchFlagSet.IntVar(&maxRetries, "retries", 3, "Retry failed requests up to this many times")
+	fetchFlagSet.Float64Var(&rateLimit, "rps", 0, "Max requests per second (0 = unlimited)")
+	fetchFlagSet.Usage = func() {
+		fmt.Fprintf(os.Stdout, "%s %s () %s\n", appName, appVer, appDesc)
+		fmt.Fprintf(os.Stdout, "download font files listed in fonts data in JSON format\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Usage: %s fetch -i <file.json> -d <dir> [-o <manifest.json>] [-f]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+		fetchFlagSet.PrintDefaults()
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Files are saved as <dir>/<family>/<version>/<filename>.\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Example:\n")
+		fmt.Fprintf(os.Stdout, "    %s fetch -i font.json -d fonts -o manifest.json\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+	}
+
+	selfhostFlagSet := flag.NewFlagSet("selfhost", flag.ExitOnError)
+	selfhostFlagSet.StringVar(&infile, "i", "", "Input file (mandatory)")
+	selfhostFlagSet.StringVar(&outdir, "d", "", "Output directory (mandatory)")
+	selfhostFlagSet.StringVar(&outfile, "o", "", "Output CSS to file or stdout (default <dir>/fonts.css)")
+	selfhostFlagSet.StringVar(&baseURL, "b", "", "Base URL of the output directory (default relative to the CSS)")
+	selfhostFlagSet.BoolVar(&overwrite, "f", false, "Download files already present again")
+	selfhostFlagSet.StringVar(&subsets, "u", "", "Only self-host these subsets, comma separated (default all)")
+	selfhostFlagSet.BoolVar(&pretty, "H", false, "Human readable")
+	selfhostFlagSet.BoolVar(&compatMode, "c", false, "Max legacy compatibility")
+	selfhostFlagSet.IntVar(&maxRetries, "retries", 3, "Retry failed requests up to this many times")
+	selfhostFlagSet.Float64Var(&rateLimit, "rps", 0, "Max requests per second (0 = unlimited)")
+	selfhostFlagSet.Usage = func() {
+		fmt.Fprintf(os.Stdout, "%s %s () %s\n", appName, appVer, appDesc)
+		fmt.Fprintf(os.Stdout, "download font files and create CSS that points to them\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Usage: %s selfhost -i <file.json> -d <dir> [-b <url>] [-o <file.css>] [-u <subsets>] [-f] [-c] [-H]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+		selfhostFlagSet.PrintDefaults()
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Example:\n")
+		fmt.Fprintf(os.Stdout, "    %s selfhost -i all.json -d public/static/fonts -b /static/fonts -H\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "    %s selfhost -i all.json -d public/static/fonts -u latin,latin-ext\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+	}
+
+	lockFlagSet := flag.NewFlagSet("lock", flag.ExitOnError)
+	lockFlagSet.StringVar(&lockFile, "f", gfont.LockFileName, "Lock file")
+	lockFlagSet.Var(&fontFamily, "t", "Font name (repeatable, default families in the lock file)")
+	lockFlagSet.Var(&fontStyle, "s", "Font style params for the matching -t (repeatable)")
+	lockFlagSet.StringVar(&lockProfiles, "p", "all", "Comma separated font profiles")
+	lockFlagSet.StringVar(&mirrorProxy, "m", "", "Mirror proxy")
+	lockFlagSet.IntVar(&maxRetries, "retries", 3, "Retry failed requests up to this many times")
+	lockFlagSet.Float64Var(&rateLimit, "rps", 0, "Max requests per second (0 = unlimited)")
+	lockFlagSet.Usage = func() {
+		fmt.Fprintf(os.Stdout, "%s %s () %s\n", appName, appVer, appDesc)
+		fmt.Fprintf(os.Stdout, "create or update a lock file with the URL, version and SHA-256 of each font file\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Usage: %s lock [-f <gfont.lock>] [-t <family> -s <style>...] [-p <profile>,...] [-m <url>]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+		lockFlagSet.PrintDefaults()
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Without -t, the families and profiles already in the lock file are resolved again.\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Example:\n")
+		fmt.Fprintf(os.Stdout, "    %s lock -t Domine -s 'wght@400;700' -p woff2,woff\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "    %s lock\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+	}
+
+	verifyFlagSet := flag.NewFlagSet("verify", flag.ExitOnError)
+	verifyFlagSet.StringVar(&lockFile, "f", gfont.LockFileName, "Lock file")
+	verifyFlagSet.StringVar(&mirrorProxy, "m", "", "Mirror proxy")
+	verifyFlagSet.IntVar(&maxRetries, "retries", 3, "Retry failed requests up to this many times")
+	verifyFlagSet.Float64Var(&rateLimit, "rps", 0, "Max requests per second (0 = unlimited)")
+	verifyFlagSet.Usage = func() {
+		fmt.Fprintf(os.Stdout, "%s %s () %s\n", appName, appVer, appDesc)
+		fmt.Fprintf(os.Stdout, "check that the CSS and font files served by Google still match a lock file\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Usage: %s verify [-f <gfont.lock>] [-m <url>]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+		verifyFlagSet.PrintDefaults()
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Exits with code 4 if anything drifted.\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Example:\n")
+		fmt.Fprintf(os.Stdout, "    %s verify -f gfont.lock\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+	}
+
+	coverageFlagSet := flag.NewFlagSet("coverage", flag.ExitOnError)
+	coverageFlagSet.StringVar(&infile, "i", "", "Input file (mandatory)")
+	coverageFlagSet.StringVar(&outfile, "o", "-", "Output to file or stdout")
+	coverageFlagSet.StringVar(&text, "text", "", "Text to cover")
+	coverageFlagSet.StringVar(&textFile, "text-file", "", "Read the text to cover from a file")
+	coverageFlagSet.Usage = func() {
+		fmt.Fprintf(os.Stdout, "%s %s () %s\n", appName, appVer, appDesc)
+		fmt.Fprintf(os.Stdout, "find the font files needed to render a text, and the characters no font covers\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Usage: %s coverage -i <file.json> (--text <text> | --text-file <file.txt>) [-o <file.json>]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+		coverageFlagSet.PrintDefaults()
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Exits with code 4 if some characters are not covered.\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Example:\n")
+		fmt.Fprintf(os.Stdout, "    %s coverage -i fonts.json --text-file strings.txt\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+	}
+
+	infoFlagSet := flag.NewFlagSet("info", flag.ExitOnError)
+	infoFlagSet.StringVar(&outfile, "o", "-", "Output to file or stdout")
+	infoFlagSet.BoolVar(&verifyFont, "verify", false, "Verify table checksums, and the metadata and private blocks of WOFF files")
+	infoFlagSet.Usage = func() {
+		fmt.Fprintf(os.Stdout, "%s %s () %s\n", appName, appVer, appDesc)
+		fmt.Fprintf(os.Stdout, "show the names, metrics, axes and characters of a TTF, OTF, WOFF or WOFF2 font file in JSON format\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Usage: %s info [-o <file.json>] [-verify] <file.ttf>\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+		infoFlagSet.PrintDefaults()
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Example:\n")
+		fmt.Fprintf(os.Stdout, "    %s info fonts/domine/v20/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1g.ttf\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "    %s info -verify fonts/domine/v20/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1g.woff2\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+	}
+
+	convertFlagSet := flag.NewFlagSet("convert", flag.ExitOnError)
+	convertFlagSet.StringVar(&infile, "i", "", "Input font file (mandatory)")
+	convertFlagSet.StringVar(&fontFormat, "f", "", "Output format: woff2, woff, ttf or otf (mandatory)")
+	convertFlagSet.StringVar(&outfile, "o", "", "Output to file or stdout (default: the input file with the extension of the format)")
+	convertFlagSet.Usage = func() {
+		fmt.Fprintf(os.Stdout, "%s %s () %s\n", appName, appVer, appDesc)
+		fmt.Fprintf(os.Stdout, "convert a TTF, OTF, WOFF or WOFF2 font file to another format\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Usage: %s convert -i <file.ttf> -f <format> [-o <file>]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+		convertFlagSet.PrintDefaults()
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "ttf and otf both write the decoded font: outlines are not converted between TrueType and CFF.\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Example:\n")
+		fmt.Fprintf(os.Stdout, "    %s convert -i fonts/Domine-Regular.ttf -f woff2\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "    %s convert -i fonts/Domine-Regular.woff2 -f ttf -o Domine-Regular.ttf\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+	}
+
+	subsetFlagSet := flag.NewFlagSet("subset", flag.ExitOnError)
+	subsetFlagSet.StringVar(&infile, "i", "", "Input font file (mandatory)")
+	subsetFlagSet.StringVar(&fontFormat, "f", "woff2", "Output format: woff2, woff or ttf")
+	subsetFlagSet.StringVar(&outfile, "o", "", "Output font to file or stdout (default <file>.subset.<format>), or the CSS with -j (default <dir>/fonts.css)")
+	subsetFlagSet.StringVar(&text, "text", "", "Keep the characters of a text")
+	subsetFlagSet.StringVar(&textFile, "text-file", "", "Keep the characters of a text file")
+	subsetFlagSet.StringVar(&unicodeRange, "r", "", "Keep the characters of a unicode range, such as U+0000-00FF,U+0131")
+	subsetFlagSet.StringVar(&splitFile, "j", "", "Split into one file per unicode range of the fonts in a JSON file")
+	subsetFlagSet.StringVar(&outdir, "d", "", "Output directory of the files split with -j (mandatory with -j)")
+	subsetFlagSet.StringVar(&baseURL, "b", "", "Base URL of the output directory (default relative to the CSS)")
+	subsetFlagSet.StringVar(&subsets, "u", "", "Only split these subsets, comma separated (default all)")
+	subsetFlagSet.BoolVar(&dropLayout, "drop-layout", false, "Drop OpenType layout features, such as ligatures and kerning, for smaller files")
+	subsetFlagSet.BoolVar(&pretty, "H", false, "Human readable")
+	subsetFlagSet.BoolVar(&compatMode, "c", false, "Max legacy compatibility")
+	subsetFlagSet.Usage = func() {
+		fmt.Fprintf(os.Stdout, "%s %s () %s\n", appName, appVer, appDesc)
+		fmt.Fprintf(os.Stdout, "reduce a TTF, WOFF or WOFF2 font file to some characters, or split it into css2 style subsets\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Usage: %s subset -i <file.ttf> (--text <text> | --text-file <file.txt> | -r <range>) [-f <format>] [-o <file>] [--drop-layout]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "       %s subset -i <file.ttf> -j <file.json> -d <dir> [-f <format>] [-o <file.css>] [-b <url>] [-u <subsets>] [--drop-layout] [-c] [-H]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+		subsetFlagSet.PrintDefaults()
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Only fonts with TrueType outlines are supported. Exits with code 4 if the font lacks some characters of\n")
+		fmt.Fprintf(os.Stdout, "the text or range.\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Example:\n")
+		fmt.Fprintf(os.Stdout, "    %s subset -i Domine-Regular.ttf --text 'Hello, world' -o logo.woff2\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "    %s download -t Domine -s 'wght@400' | %s parse -i - -o domine.json\n", os.Args[0], os.Args[0])
+		fmt.Fprintf(os.Stdout, "    %s subset -i Domine-Regular.ttf -j domine.json -d public/fonts -b /fonts\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+	}
+
+	auditFlagSet := flag.NewFlagSet("audit", flag.ExitOnError)
+	auditFlagSet.StringVar(&infile, "i", "", "Input file (mandatory)")
+	auditFlagSet.StringVar(&outdir, "d", "", "Directory of the font files, downloaded if not present (mandatory)")
+	auditFlagSet.StringVar(&outfile, "o", "-", "Output to file or stdout")
+	auditFlagSet.BoolVar(&overwrite, "f", false, "Download files already present again")
+	auditFlagSet.IntVar(&maxRetries, "retries", 3, "Retry failed requests up to this many times")
+	auditFlagSet.Float64Var(&rateLimit, "rps", 0, "Max requests per second (0 = unlimited)")
+	auditFlagSet.Usage = func() {
+		fmt.Fprintf(os.Stdout, "%s %s () %s\n", appName, appVer, appDesc)
+		fmt.Fprintf(os.Stdout, "check that the font files have a glyph for every character of their unicode range, in JSON format\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Usage: %s audit -i <file.json> -d <dir> [-o <file.json>] [-f]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+		auditFlagSet.PrintDefaults()
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Files are saved as <dir>/<family>/<version>/<filename>, as with fetch. Characters a file has outside of its\n")
+		fmt.Fprintf(os.Stdout, "unicode range are reported as extra. Exits with code 4 if some characters of a unicode range are missing.\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "Example:\n")
+		fmt.Fprintf(os.Stdout, "    %s audit -i fonts.json -d fonts -o audit.json\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "\n")
+	}
+
 	// gfont download -t Domine -s 'wght@400;500;600;700' | gfont parse -i -
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stdout, "%s %s () %s\n", appName, appVer, appDesc)
 		fmt.Fprintln(os.Stdout, "")
-		fmt.Fprintf(os.Stdout, "Usage: %s download -t <family> -s <style> [-p <format>] [-o <file.css>] [-v]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "Usage: %s download -t <family> -s <style> [-t <family> -s <style>...] [-p <format>] [-o <file.css>] [-v]\n", os.Args[0])
 		fmt.Fprintf(os.Stdout, "       %s parse -i <file.css> [-o <file.json>]\n", os.Args[0])
 		fmt.Fprintf(os.Stdout, "       %s filter -i <file.json> -q <field>\n", os.Args[0])
 		fmt.Fprintf(os.Stdout, "       %s merge [-o <file.css>] <file1.json> [<file2.json>...]\n", os.Args[0])
 		fmt.Fprintf(os.Stdout, "       %s css -i <file.json> [-o <file.css>] [-c] [-H]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "       %s fetch -i <file.json> -d <dir> [-o <manifest.json>] [-f]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "       %s selfhost -i <file.json> -d <dir> [-b <url>] [-o <file.css>] [-u <subsets>] [-f] [-c] [-H]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "       %s lock [-f <gfont.lock>] [-t <family> -s <style>...] [-p <profile>,...]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "       %s verify [-f <gfont.lock>]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "       %s coverage -i <file.json> (--text <text> | --text-file <file.txt>) [-o <file.json>]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "       %s info [-o <file.json>] [-verify] <file.ttf>\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "       %s convert -i <file.ttf> -f <format> [-o <file>]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "       %s audit -i <file.json> -d <dir> [-o <file.json>] [-f]\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "       %s subset -i <file.ttf> (--text <text> | --text-file <file.txt> | -r <range> | -j <file.json> -d <dir>) [-f <format>] [-o <file>]\n", os.Args[0])
 		fmt.Fprintln(os.Stdout, "")
 		fmt.Fprintln(os.Stdout, "To view parameters for each subcommand:")
 		fmt.Fprintf(os.Stdout, "    %s -h <subcommand>\n", os.Args[0])
@@ -167,14 +418,26 @@ func init() {
 			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
 			os.Exit(1)
 		}
-		if _, ok := fpArgmap[fontProfile]; !ok {
+		if _, ok := fpArgmap[fontProfile]; !ok && fontProfile != "all" {
 			fmt.Fprintf(os.Stderr, "subcommand %s: unsupported font profile\n", cmdlet)
 			os.Exit(1)
 		}
-		if fontFamily == "" {
+		if len(fontFamily) == 0 {
 			fmt.Fprintf(os.Stderr, "subcommand %s: -t <font> mandatory\n", cmdlet)
 			os.Exit(1)
 		}
+		if len(fontStyle) > len(fontFamily) {
+			fmt.Fprintf(os.Stderr, "subcommand %s: each -s <style> must follow a -t <font>\n", cmdlet)
+			os.Exit(1)
+		}
+		if offline && cacheDir == "" {
+			fmt.Fprintf(os.Stderr, "subcommand %s: --offline requires --cache-dir <dir>\n", cmdlet)
+			os.Exit(1)
+		}
+		if text != "" && textFile != "" {
+			fmt.Fprintf(os.Stderr, "subcommand %s: --text and --text-file are exclusive\n", cmdlet)
+			os.Exit(1)
+		}
 	case "parse":
 		if err := parseFlagSet.Parse(os.Args[2:]); err != nil {
 			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
@@ -212,6 +475,158 @@ func init() {
 			fmt.Fprintf(os.Stderr, "subcommand %s: -i <file> mandatory\n", cmdlet)
 			os.Exit(1)
 		}
+	case "fetch":
+		if err := fetchFlagSet.Parse(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+		if infile == "" {
+			fmt.Fprintf(os.Stderr, "subcommand %s: -i <file> mandatory\n", cmdlet)
+			os.Exit(1)
+		}
+		if outdir == "" {
+			fmt.Fprintf(os.Stderr, "subcommand %s: -d <dir> mandatory\n", cmdlet)
+			os.Exit(1)
+		}
+	case "selfhost":
+		if err := selfhostFlagSet.Parse(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+		if infile == "" {
+			fmt.Fprintf(os.Stderr, "subcommand %s: -i <file> mandatory\n", cmdlet)
+			os.Exit(1)
+		}
+		if outdir == "" {
+			fmt.Fprintf(os.Stderr, "subcommand %s: -d <dir> mandatory\n", cmdlet)
+			os.Exit(1)
+		}
+		if outfile == "" {
+			outfile = filepath.Join(outdir, "fonts.css")
+		}
+	case "lock":
+		if err := lockFlagSet.Parse(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+		if len(fontStyle) > len(fontFamily) {
+			fmt.Fprintf(os.Stderr, "subcommand %s: each -s <style> must follow a -t <font>\n", cmdlet)
+			os.Exit(1)
+		}
+		if _, err := parseProfiles(lockProfiles); err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+	case "verify":
+		if err := verifyFlagSet.Parse(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+	case "coverage":
+		if err := coverageFlagSet.Parse(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+		if infile == "" {
+			fmt.Fprintf(os.Stderr, "subcommand %s: -i <file> mandatory\n", cmdlet)
+			os.Exit(1)
+		}
+		if (text == "") == (textFile == "") {
+			fmt.Fprintf(os.Stderr, "subcommand %s: either --text or --text-file mandatory\n", cmdlet)
+			os.Exit(1)
+		}
+	case "info":
+		if err := infoFlagSet.Parse(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+		if infoFlagSet.NArg() != 1 {
+			fmt.Fprintf(os.Stderr, "subcommand %s: expect one font file\n", cmdlet)
+			os.Exit(1)
+		}
+		infile = infoFlagSet.Arg(0)
+	case "convert":
+		if err := convertFlagSet.Parse(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+		if infile == "" {
+			fmt.Fprintf(os.Stderr, "subcommand %s: -i <file> mandatory\n", cmdlet)
+			os.Exit(1)
+		}
+		switch fontFormat {
+		case "woff2", "woff", "ttf", "otf":
+		case "":
+			fmt.Fprintf(os.Stderr, "subcommand %s: -f <format> mandatory\n", cmdlet)
+			os.Exit(1)
+		default:
+			fmt.Fprintf(os.Stderr, "subcommand %s: unknown format %s\n", cmdlet, fontFormat)
+			os.Exit(1)
+		}
+		if outfile == "" {
+			if infile == "-" {
+				outfile = "-"
+			} else {
+				outfile = strings.TrimSuffix(infile, filepath.Ext(infile)) + "." + fontFormat
+			}
+		}
+		if outfile == infile {
+			fmt.Fprintf(os.Stderr, "subcommand %s: output would overwrite the input file\n", cmdlet)
+			os.Exit(1)
+		}
+	case "subset":
+		if err := subsetFlagSet.Parse(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+		if infile == "" {
+			fmt.Fprintf(os.Stderr, "subcommand %s: -i <file> mandatory\n", cmdlet)
+			os.Exit(1)
+		}
+		modes := 0
+		for _, v := range []string{text, textFile, unicodeRange, splitFile} {
+			if v != "" {
+				modes++
+			}
+		}
+		if modes != 1 {
+			fmt.Fprintf(os.Stderr, "subcommand %s: either --text, --text-file, -r or -j mandatory\n", cmdlet)
+			os.Exit(1)
+		}
+		switch fontFormat {
+		case "woff2", "woff", "ttf":
+		default:
+			fmt.Fprintf(os.Stderr, "subcommand %s: unknown format %s\n", cmdlet, fontFormat)
+			os.Exit(1)
+		}
+		if splitFile != "" {
+			if outdir == "" {
+				fmt.Fprintf(os.Stderr, "subcommand %s: -d <dir> mandatory with -j\n", cmdlet)
+				os.Exit(1)
+			}
+			if outfile == "" {
+				outfile = filepath.Join(outdir, "fonts.css")
+			}
+		} else if outfile == "" {
+			if infile == "-" {
+				outfile = "-"
+			} else {
+				outfile = strings.TrimSuffix(infile, filepath.Ext(infile)) + ".subset." + fontFormat
+			}
+		}
+	case "audit":
+		if err := auditFlagSet.Parse(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+		if infile == "" {
+			fmt.Fprintf(os.Stderr, "subcommand %s: -i <file> mandatory\n", cmdlet)
+			os.Exit(1)
+		}
+		if outdir == "" {
+			fmt.Fprintf(os.Stderr, "subcommand %s: -d <dir> mandatory\n", cmdlet)
+			os.Exit(1)
+		}
 	default:
 		if cmdlet == "-h" || cmdlet == "--help" {
 			if len(os.Args) < 3 {
@@ -226,6 +641,15 @@ func init() {
 			case "filter":   filterFlagSet.Usage()
 			case "merge":    mergeFlagSet.Usage()
 			case "css":      renderFlagSet.Usage()
+			case "fetch":    fetchFlagSet.Usage()
+			case "selfhost": selfhostFlagSet.Usage()
+			case "lock":     lockFlagSet.Usage()
+			case "verify":   verifyFlagSet.Usage()
+			case "coverage": coverageFlagSet.Usage()
+			case "info":     infoFlagSet.Usage()
+			case "convert":  convertFlagSet.Usage()
+			case "subset":   subsetFlagSet.Usage()
+			case "audit":    auditFlagSet.Usage()
 			default:
 				fmt.Fprintf(os.Stderr, "invalid help topic: %s\n", subtopic)
 				flag.Usage()
@@ -261,8 +685,8 @@ func readFile(path string) ([]byte, error) {
 
 func writeFile(content []byte, outPath string) error {
 	if outPath == "-" || outPath == "" {
-		fmt.Printf(string(content))
-		return nil
+		_, err := os.Stdout.Write(content)
+		return err
 	}
 
 	errWrite := ioutil.WriteFile(outPath, content, 0644)
@@ -273,24 +697,173 @@ func writeFile(content []byte, outPath string) error {
 	return nil
 }
 
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
+
+// familyQuery pairs each family with the style at the same position
+func familyQuery(families, styles []string) (*gfont.Query, error) {
+	q := gfont.NewQuery()
+	for i, fam := range families {
+		style := ""
+		if i < len(styles) {
+			style = styles[i]
+		}
+
+		fs, err := gfont.ParseFamilySpec(fam, style)
+		if err != nil {
+			return nil, err
+		}
+		q.Add(fs)
+	}
+
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// renderCSS renders typefaces according to the -c and -H flags
+func renderCSS(typefaces *gfont.Typefaces) string {
+	if compatMode {
+		if pretty {
+			return typefaces.PrettyCSS()
+		}
+		return typefaces.CSS()
+	}
+
+	r := make([]string, len(typefaces.Fonts))
+	for i, v := range typefaces.Fonts {
+		if pretty {
+			r[i] = v.PrettyCSS()
+		} else {
+			r[i] = v.CSS()
+		}
+	}
+
+	sep := ""
+	if pretty {
+		sep = "\n"
+	}
+	return strings.Join(r, sep)
+}
+
+// parseProfiles parses a comma separated list of font profiles. all stands for every format.
+func parseProfiles(arg string) ([]gfont.FontProfile, error) {
+	result := []gfont.FontProfile{}
+	for _, name := range strings.Split(arg, ",") {
+		if name == "all" {
+			result = append(result, gfont.CompatProfiles...)
+			continue
+		}
+
+		fp, ok := fpArgmap[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported font profile %s", name)
+		}
+		result = append(result, fp)
+	}
+	return result, nil
+}
+
+// parseMirror parses the -m flag
+func parseMirror(arg string) *url.URL {
+	if arg == "" {
+		return nil
+	}
+
+	mir, errURL := url.Parse(arg)
+	if errURL != nil {
+		panic(errURL)
+	}
+	return mir
+}
+
+// newClient returns a client configured by the common network flags
+func newClient(mirror *url.URL) *gfont.Client {
+	client := &gfont.Client{BaseURL: mirror}
+	client.Retry = gfont.DefaultRetryPolicy()
+	client.Retry.MaxAttempts = maxRetries + 1
+	if rateLimit > 0 {
+		client.Limiter = gfont.NewRateLimiter(rateLimit, 1)
+	}
+	return client
+}
+
+// exitCode maps download errors to process exit codes
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, gfont.ErrUnknownFamily), errors.Is(err, gfont.ErrInvalidAxis):
+		return 2
+	case errors.Is(err, gfont.ErrRateLimited), errors.Is(err, gfont.ErrServer):
+		return 3
+	default:
+		return 1
+	}
+}
+
 func main() {
 	switch cmdlet {
 	case "download":
-		var mir *url.URL
-		if mirrorProxy != "" {
-			var errURL error
-			mir, errURL = url.Parse(mirrorProxy)
-			if errURL != nil {
-				panic(errURL)
+		mir := parseMirror(mirrorProxy)
+		if textFile != "" {
+			textBytes, errText := readFile(textFile)
+			if errText != nil {
+				panic(errText)
 			}
+			text = strings.TrimRight(string(textBytes), "\r\n")
+		}
+
+		q, errQuery := familyQuery(fontFamily, fontStyle)
+		if errQuery != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, errQuery)
+			os.Exit(2)
+		}
+		q.SetText(text)
+		if errText := q.Validate(); errText != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, errText)
+			os.Exit(1)
+		}
+
+		client := newClient(mir)
+		if cacheDir != "" {
+			client.Cache = gfont.NewCache(cacheDir, cacheTTL)
+			client.Cache.Offline = offline
 		}
-		cssURL := gfont.GetURL(fpArgmap[fontProfile], fontFamily, fontStyle, mir)
 		if verbose {
-			fmt.Printf("[INFO] HTTP:GET %s\n", cssURL)
+			fmt.Printf("[INFO] HTTP:GET %s\n", client.QueryURL(q))
+		}
+
+		if fontProfile == "all" {
+			typefaces, err := client.DownloadAll(context.Background(), q)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+				os.Exit(exitCode(err))
+			}
+
+			jsonBytes, errJSON := json.Marshal(typefaces)
+			if errJSON != nil {
+				panic(errJSON)
+			}
+			err = writeFile(jsonBytes, outfile)
+			if err != nil {
+				panic(err)
+			}
+			break
 		}
-		cssBytes, err := gfont.DownloadCSS(fpArgmap[fontProfile], fontFamily, fontStyle, mir)
+
+		cssBytes, err := client.Download(context.Background(), fpArgmap[fontProfile], q)
 		if err != nil {
-			panic(err)
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(exitCode(err))
 		}
 		err = writeFile(cssBytes, outfile)
 		if err != nil {
@@ -344,6 +917,11 @@ func main() {
 			for _, v := range filtered {
 				fmt.Printf("%s\n", v)
 			}
+		} else if filterField == "subset" {
+			filtered := typefaces.Subset()
+			for _, v := range filtered {
+				fmt.Printf("%s\n", v)
+			}
 		} else {
 			panic(fmt.Errorf("unsupported field %s", filterField))
 		}
@@ -387,34 +965,360 @@ func main() {
 			panic(err)
 		}
 
-		var result string
-		if compatMode {
-			if pretty {
-				result = typefaces.PrettyCSS()
-			} else {
-				result = typefaces.CSS()
+		err = writeFile([]byte(renderCSS(&typefaces)), outfile)
+		if err != nil {
+			panic(err)
+		}
+	case "fetch":
+		jsonBytes, err := readFile(infile)
+		if err != nil {
+			panic(err)
+		}
+
+		var typefaces gfont.Typefaces
+		err = json.Unmarshal(jsonBytes, &typefaces)
+		if err != nil {
+			panic(err)
+		}
+
+		dl := gfont.NewDownloader(outdir)
+		dl.Client = newClient(nil)
+		dl.Overwrite = overwrite
+		manifest, err := dl.Download(context.Background(), &typefaces)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(exitCode(err))
+		}
+
+		manifestBytes, errJSON := json.Marshal(manifest)
+		if errJSON != nil {
+			panic(errJSON)
+		}
+		err = writeFile(manifestBytes, outfile)
+		if err != nil {
+			panic(err)
+		}
+	case "selfhost":
+		jsonBytes, err := readFile(infile)
+		if err != nil {
+			panic(err)
+		}
+
+		var typefaces gfont.Typefaces
+		err = json.Unmarshal(jsonBytes, &typefaces)
+		if err != nil {
+			panic(err)
+		}
+
+		if subsets != "" {
+			typefaces.Fonts = typefaces.Select("", "", "", -1, strings.Split(subsets, ",")...)
+			if len(typefaces.Fonts) == 0 {
+				fmt.Fprintf(os.Stderr, "subcommand %s: no font in subsets %s\n", cmdlet, subsets)
+				os.Exit(1)
+			}
+		}
+
+		dl := gfont.NewDownloader(outdir)
+		dl.Client = newClient(nil)
+		dl.Overwrite = overwrite
+		hosted, _, err := gfont.SelfHost(context.Background(), dl, &typefaces, baseURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(exitCode(err))
+		}
+
+		err = writeFile([]byte(renderCSS(hosted)), outfile)
+		if err != nil {
+			panic(err)
+		}
+	case "lock":
+		client := newClient(parseMirror(mirrorProxy))
+
+		var lock *gfont.Lock
+		var err error
+		if len(fontFamily) == 0 {
+			oldLock, errRead := gfont.ReadLock(lockFile)
+			if errRead != nil {
+				fmt.Fprintf(os.Stderr, "subcommand %s: -t <font> mandatory without a lock file: %v\n", cmdlet, errRead)
+				os.Exit(1)
 			}
+			lock, err = client.UpdateLock(context.Background(), oldLock)
 		} else {
-			r := make([]string, len(typefaces.Fonts))
-			for i, v := range typefaces.Fonts {
-				if pretty {
-					r[i] = v.PrettyCSS()
-				} else {
-					r[i] = v.CSS()
+			q, errQuery := familyQuery(fontFamily, fontStyle)
+			if errQuery != nil {
+				fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, errQuery)
+				os.Exit(2)
+			}
+			profiles, _ := parseProfiles(lockProfiles)
+			lock, err = client.CreateLock(context.Background(), q, profiles...)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(exitCode(err))
+		}
+
+		err = lock.Write(lockFile)
+		if err != nil {
+			panic(err)
+		}
+	case "verify":
+		lock, err := gfont.ReadLock(lockFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+
+		client := newClient(parseMirror(mirrorProxy))
+		drifts, err := client.VerifyLock(context.Background(), lock)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(exitCode(err))
+		}
+
+		for _, d := range drifts {
+			fmt.Printf("%s\n", d.String())
+		}
+		if len(drifts) > 0 {
+			os.Exit(4)
+		}
+	case "coverage":
+		jsonBytes, err := readFile(infile)
+		if err != nil {
+			panic(err)
+		}
+
+		var typefaces gfont.Typefaces
+		err = json.Unmarshal(jsonBytes, &typefaces)
+		if err != nil {
+			panic(err)
+		}
+
+		if textFile != "" {
+			textBytes, errText := readFile(textFile)
+			if errText != nil {
+				panic(errText)
+			}
+			text = string(textBytes)
+		}
+
+		coverage := typefaces.ForText(text)
+		jsonBytes, errJSON := json.Marshal(coverage)
+		if errJSON != nil {
+			panic(errJSON)
+		}
+
+		err = writeFile(jsonBytes, outfile)
+		if err != nil {
+			panic(err)
+		}
+		if len(coverage.Missing) > 0 {
+			fmt.Fprintf(os.Stderr, "no font covers %s\n", coverage.Missing.String())
+			os.Exit(4)
+		}
+	case "info":
+		fontBytes, err := readFile(infile)
+		if err != nil {
+			panic(err)
+		}
+
+		fontBytes, err = sfnt.Decode(fontBytes, &sfnt.DecodeOptions{VerifyChecksums: verifyFont, VerifyBlocks: verifyFont})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+		font, err := sfnt.Parse(fontBytes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+		info, err := font.Info()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+
+		ranges := make([]gfont.RuneRange, len(info.Runes))
+		for i, r := range info.Runes {
+			ranges[i] = gfont.RuneRange{First: r, Last: r}
+		}
+		output := struct {
+			*sfnt.Info
+			UnicodeRange gfont.UnicodeRange `json:"unicodeRange"`
+		}{info, gfont.NewUnicodeRange(ranges...)}
+
+		jsonBytes, errJSON := json.Marshal(output)
+		if errJSON != nil {
+			panic(errJSON)
+		}
+		err = writeFile(jsonBytes, outfile)
+		if err != nil {
+			panic(err)
+		}
+	case "convert":
+		fontBytes, err := readFile(infile)
+		if err != nil {
+			panic(err)
+		}
+
+		fontBytes, err = sfnt.Decode(fontBytes, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+		switch fontFormat {
+		case "woff2":
+			fontBytes, err = sfnt.EncodeWOFF2(fontBytes)
+		case "woff":
+			fontBytes, err = sfnt.EncodeWOFF(fontBytes)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+
+		err = writeFile(fontBytes, outfile)
+		if err != nil {
+			panic(err)
+		}
+	case "subset":
+		fontBytes, err := readFile(infile)
+		if err != nil {
+			panic(err)
+		}
+
+		if splitFile != "" {
+			jsonBytes, err := readFile(splitFile)
+			if err != nil {
+				panic(err)
+			}
+			var typefaces gfont.Typefaces
+			err = json.Unmarshal(jsonBytes, &typefaces)
+			if err != nil {
+				panic(err)
+			}
+			if subsets != "" {
+				typefaces.Fonts = typefaces.Select("", "", "", -1, strings.Split(subsets, ",")...)
+			}
+
+			format := fontFormat
+			if format == "ttf" {
+				format = "truetype"
+			}
+			split, files, err := gfont.SplitFont(fontBytes, gfont.Typeface{}, &typefaces, &gfont.SplitOptions{Format: format, BaseURL: baseURL, DropLayout: dropLayout})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+				os.Exit(1)
+			}
+			if len(files) == 0 {
+				fmt.Fprintf(os.Stderr, "subcommand %s: the font has no character of the unicode ranges in %s\n", cmdlet, splitFile)
+				os.Exit(4)
+			}
+
+			err = os.MkdirAll(outdir, 0755)
+			if err != nil {
+				panic(err)
+			}
+			for _, f := range files {
+				err = writeFile(f.Data, filepath.Join(outdir, f.Name))
+				if err != nil {
+					panic(err)
 				}
 			}
+			err = writeFile([]byte(renderCSS(split)), outfile)
+			if err != nil {
+				panic(err)
+			}
+			return
+		}
 
-			sep := ""
-			if pretty {
-				sep = "\n"
+		var wanted gfont.UnicodeRange
+		switch {
+		case unicodeRange != "":
+			wanted, err = gfont.ParseUnicodeRange(unicodeRange)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+				os.Exit(1)
+			}
+		case textFile != "":
+			textBytes, errText := readFile(textFile)
+			if errText != nil {
+				panic(errText)
 			}
-			result = strings.Join(r, sep)
+			wanted = gfont.UnicodeRangeOf(string(textBytes))
+		default:
+			wanted = gfont.UnicodeRangeOf(text)
+		}
+
+		subsetBytes, err := sfnt.Subset(fontBytes, wanted.Contains, &sfnt.SubsetOptions{DropLayout: dropLayout})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+		font, err := sfnt.Parse(subsetBytes)
+		if err != nil {
+			panic(err)
+		}
+		cmap, err := font.Cmap()
+		if err != nil {
+			panic(err)
+		}
+		switch fontFormat {
+		case "woff2":
+			subsetBytes, err = sfnt.EncodeWOFF2(subsetBytes)
+		case "woff":
+			subsetBytes, err = sfnt.EncodeWOFF(subsetBytes)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(1)
+		}
+
+		err = writeFile(subsetBytes, outfile)
+		if err != nil {
+			panic(err)
+		}
+
+		if missing := gfont.MissingRunes(wanted, cmap); len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "the font lacks %s\n", missing.String())
+			os.Exit(4)
+		}
+	case "audit":
+		jsonBytes, err := readFile(infile)
+		if err != nil {
+			panic(err)
+		}
+
+		var typefaces gfont.Typefaces
+		err = json.Unmarshal(jsonBytes, &typefaces)
+		if err != nil {
+			panic(err)
+		}
+
+		dl := gfont.NewDownloader(outdir)
+		dl.Client = newClient(nil)
+		dl.Overwrite = overwrite
+		audit, err := dl.Audit(context.Background(), &typefaces)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "subcommand %s: %v\n", cmdlet, err)
+			os.Exit(exitCode(err))
 		}
 
-		err = writeFile([]byte(result), outfile)
+		auditBytes, errJSON := json.Marshal(audit)
+		if errJSON != nil {
+			panic(errJSON)
+		}
+		err = writeFile(auditBytes, outfile)
 		if err != nil {
 			panic(err)
 		}
+		if !audit.OK() {
+			for _, f := range audit.Fonts {
+				if len(f.Missing) > 0 {
+					fmt.Fprintf(os.Stderr, "%s lacks %s\n", f.URL, f.Missing.String())
+				}
+			}
+			os.Exit(4)
+		}
 	default:
 		panic(fmt.Errorf("unexpected fallthrough"))
 	}
@@ -3,5 +3,6 @@ module github.com/imacks/gfont
 go 1.14
 
 require (
+   "github.com/andybalholm/brotli" v1.1.0
    "github.com/gorilla/css" v1.0.0
 )
\ No newline at end of file
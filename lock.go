@@ -0,0 +1,276 @@
+package gfont
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// LockFileName is the default name of a lock file
+const LockFileName = "gfont.lock"
+
+// lockVersion is the version of the lock file format
+const lockVersion = 1
+
+// Lock records the fonts resolved for a Query, so that a build can detect when Google changes them
+type Lock struct {
+	LockVersion int `json:"lockVersion"`
+	// Query is the canonical css2 query string
+	Query string `json:"query"`
+	// Profiles are the names of the font profiles downloaded
+	Profiles []string    `json:"profiles"`
+	Fonts    []LockEntry `json:"fonts"`
+}
+
+// LockEntry is a font file recorded in a Lock
+type LockEntry struct {
+	Family string `json:"family"`
+	// Spec is the axis spec requested for the family, such as wght@400;700
+	Spec    string    `json:"spec,omitempty"`
+	Profile string    `json:"profile"`
+	Style   string    `json:"style"`
+	Weight  AxisValue `json:"weight"`
+	// Stretch is the width of the font, if the CSS gives one
+	Stretch *AxisValue `json:"stretch,omitempty"`
+	Format  string     `json:"format"`
+	// Subset is the css2 subset label, such as latin or [3]
+	Subset       string `json:"subset,omitempty"`
+	UnicodeRange string `json:"unicodeRange,omitempty"`
+	URL          string `json:"url"`
+	Version      string `json:"version"`
+	SHA256       string `json:"sha256"`
+}
+
+// Drift is a difference between a Lock and the fonts currently served by Google
+type Drift struct {
+	// Kind is added, removed or changed
+	Kind     string     `json:"kind"`
+	Locked   *LockEntry `json:"locked,omitempty"`
+	Upstream *LockEntry `json:"upstream,omitempty"`
+	// Reason explains a change
+	Reason string `json:"reason,omitempty"`
+}
+
+func (d Drift) String() string {
+	switch d.Kind {
+	case "added":
+		return fmt.Sprintf("added %s: %s", d.Upstream.key(), d.Upstream.URL)
+	case "removed":
+		return fmt.Sprintf("removed %s: %s", d.Locked.key(), d.Locked.URL)
+	default:
+		return fmt.Sprintf("changed %s: %s", d.Locked.key(), d.Reason)
+	}
+}
+
+// key identifies the font an entry stands for, independently of its URL and version
+func (e *LockEntry) key() string {
+	key := fmt.Sprintf("%s/%s/%s/%s", e.Profile, e.Family, e.Style, e.Weight)
+	if e.Stretch != nil {
+		key = key + "/" + e.Stretch.String() + "%"
+	}
+	key = key + "/" + e.Format
+	if e.Subset != "" {
+		key = key + "/" + e.Subset
+	}
+	if e.UnicodeRange != "" {
+		key = key + "/" + e.UnicodeRange
+	}
+	return key
+}
+
+// index maps the key of each entry of l to the entry. Two entries with the same key would make Diff compare the wrong
+// fonts, so they are an error.
+func (l *Lock) index() (map[string]*LockEntry, error) {
+	result := map[string]*LockEntry{}
+	for i := range l.Fonts {
+		key := l.Fonts[i].key()
+		if _, ok := result[key]; ok {
+			return nil, fmt.Errorf("lock has several fonts for %s", key)
+		}
+		result[key] = &l.Fonts[i]
+	}
+	return result, nil
+}
+
+// CreateLock downloads the CSS of q for each profile, and the font files it references, and records their hashes.
+// CompatProfiles is used if no profile is given. A family requested twice with different specs is an error.
+func (c *Client) CreateLock(ctx context.Context, q *Query, profiles ...FontProfile) (*Lock, error) {
+	if len(profiles) == 0 {
+		profiles = CompatProfiles
+	}
+
+	// each entry records the spec of its family, so a family must not be requested with two different specs
+	specs := map[string]string{}
+	for _, fs := range q.Families {
+		spec := fs.Spec()
+		if prev, ok := specs[fs.Family]; ok && prev != spec {
+			return nil, fmt.Errorf("family %s is requested twice, with %q and %q", fs.Family, prev, spec)
+		}
+		specs[fs.Family] = spec
+	}
+
+	ts, err := c.DownloadAll(ctx, q, profiles...)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{
+		LockVersion: lockVersion,
+		Query:       q.Encode(),
+		Fonts:       []LockEntry{},
+	}
+	for _, fp := range profiles {
+		lock.Profiles = append(lock.Profiles, fp.String())
+	}
+	for _, t := range ts.Fonts {
+		if t.URL == nil {
+			continue
+		}
+		lock.Fonts = append(lock.Fonts, LockEntry{
+			Family:       t.Family,
+			Spec:         specs[t.Family],
+			Profile:      t.Profile,
+			Style:        t.FontStyle(),
+			Weight:       t.Weight,
+			Stretch:      t.Stretch,
+			Format:       t.Format,
+			Subset:       t.Subset,
+			UnicodeRange: t.UnicodeRange.String(),
+			URL:          t.URL.String(),
+			Version:      t.Version(),
+		})
+	}
+	if _, err := lock.index(); err != nil {
+		return nil, err
+	}
+
+	err = c.forEach(ctx, len(lock.Fonts), func(ctx context.Context, i int) error {
+		body, err := c.fetchFile(ctx, lock.Fonts[i].URL)
+		if err != nil {
+			return fmt.Errorf("%s: %w", lock.Fonts[i].URL, err)
+		}
+		lock.Fonts[i].SHA256 = sha256Hex(body)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+// UpdateLock resolves the query and profiles recorded in lock again, and returns a new Lock
+func (c *Client) UpdateLock(ctx context.Context, lock *Lock) (*Lock, error) {
+	q, profiles, err := lock.request()
+	if err != nil {
+		return nil, err
+	}
+	return c.CreateLock(ctx, q, profiles...)
+}
+
+// VerifyLock resolves the query and profiles recorded in lock again, and returns how the result differs from lock.
+// No drift means the CSS and every font file are unchanged.
+func (c *Client) VerifyLock(ctx context.Context, lock *Lock) ([]Drift, error) {
+	upstream, err := c.UpdateLock(ctx, lock)
+	if err != nil {
+		return nil, err
+	}
+	return lock.Diff(upstream)
+}
+
+// Diff returns the differences between l and upstream. It fails if either lists two fonts with the same family,
+// style, weight, stretch, format, subset and unicode range in one profile.
+func (l *Lock) Diff(upstream *Lock) ([]Drift, error) {
+	locked, err := l.index()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := upstream.index(); err != nil {
+		return nil, fmt.Errorf("upstream: %w", err)
+	}
+
+	result := []Drift{}
+	seen := map[string]bool{}
+	for i := range upstream.Fonts {
+		up := &upstream.Fonts[i]
+		key := up.key()
+		seen[key] = true
+
+		old, ok := locked[key]
+		if !ok {
+			result = append(result, Drift{Kind: "added", Upstream: up})
+			continue
+		}
+
+		reasons := []string{}
+		if old.Version != up.Version {
+			reasons = append(reasons, fmt.Sprintf("version %s -> %s", old.Version, up.Version))
+		}
+		if old.URL != up.URL {
+			reasons = append(reasons, fmt.Sprintf("url %s -> %s", old.URL, up.URL))
+		}
+		if old.SHA256 != up.SHA256 {
+			reasons = append(reasons, fmt.Sprintf("sha256 %s -> %s", old.SHA256, up.SHA256))
+		}
+		if len(reasons) > 0 {
+			result = append(result, Drift{Kind: "changed", Locked: old, Upstream: up, Reason: strings.Join(reasons, ", ")})
+		}
+	}
+
+	for i := range l.Fonts {
+		if !seen[l.Fonts[i].key()] {
+			result = append(result, Drift{Kind: "removed", Locked: &l.Fonts[i]})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Kind < result[j].Kind
+	})
+	return result, nil
+}
+
+// ReadLock reads a lock file
+func ReadLock(path string) (*Lock, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{}
+	if err := json.Unmarshal(content, lock); err != nil {
+		return nil, fmt.Errorf("parse %s failed: %v", path, err)
+	}
+	if lock.LockVersion != lockVersion {
+		return nil, fmt.Errorf("%s: unsupported lock version %d", path, lock.LockVersion)
+	}
+	return lock, nil
+}
+
+// Write writes the lock file, indented so that changes are easy to review
+func (l *Lock) Write(path string) error {
+	content, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(content, '\n'))
+}
+
+func (l *Lock) request() (*Query, []FontProfile, error) {
+	q, err := ParseQuery(l.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	profiles := []FontProfile{}
+	for _, name := range l.Profiles {
+		fp, err := ParseFontProfile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		profiles = append(profiles, fp)
+	}
+	return q, profiles, nil
+}
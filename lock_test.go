@@ -0,0 +1,167 @@
+package gfont
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newLockServer returns a Client for a test server serving css2 and font files. The CSS lists the files in order, and
+// fonts maps each file path to its content.
+func newLockServer(t *testing.T, fonts map[string]string, order ...string) *Client {
+	t.Helper()
+	host := ""
+	c, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/css2" {
+			w.Write([]byte(fonts[r.URL.Path]))
+			return
+		}
+		for i, p := range order {
+			weight := []string{"400", "700"}[i%2]
+			w.Write([]byte("@font-face {\n  font-family: 'Domine';\n  font-style: normal;\n  font-weight: " + weight +
+				";\n  src: url(" + host + p + ") format('woff2');\n}\n"))
+		}
+	})
+	host = srv.URL
+	return c
+}
+
+func TestLockVerify(t *testing.T) {
+	fonts := map[string]string{"/s/domine/v20/a.woff2": "a", "/s/domine/v20/b.woff2": "b"}
+	order := []string{"/s/domine/v20/a.woff2", "/s/domine/v20/b.woff2"}
+	c := newLockServer(t, fonts, order...)
+	q := NewQuery().Family("Domine", AxisWeight).Tuple(AxisPoint(700)).Tuple(AxisPoint(400))
+
+	lock, err := c.CreateLock(context.Background(), q, WOFF2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Query != "family=Domine:wght@400;700" || strings.Join(lock.Profiles, ",") != "woff2" || len(lock.Fonts) != 2 {
+		t.Fatalf("got %+v", lock)
+	}
+	if e := lock.Fonts[1]; e.Spec != "wght@400;700" || e.Version != "v20" || e.Weight != AxisPoint(700) || e.SHA256 != sha256Hex([]byte("b")) {
+		t.Errorf("got %+v", e)
+	}
+
+	path := filepath.Join(t.TempDir(), LockFileName)
+	if err := lock.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = ReadLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drifts, err := c.VerifyLock(context.Background(), lock)
+	if err != nil || len(drifts) != 0 {
+		t.Fatalf("got %v, %v, want no drift", drifts, err)
+	}
+
+	// a new file content, and a new version of the other file
+	fonts["/s/domine/v20/a.woff2"] = "a2"
+	fonts["/s/domine/v21/b.woff2"] = "b"
+	order[1] = "/s/domine/v21/b.woff2"
+	drifts, err = c.VerifyLock(context.Background(), lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 2 || !strings.Contains(drifts[0].Reason, "sha256") || !strings.Contains(drifts[1].Reason, "version v20 -> v21") {
+		t.Errorf("got %v", drifts)
+	}
+}
+
+func TestLockDiff(t *testing.T) {
+	locked := &Lock{Fonts: []LockEntry{
+		{Family: "Domine", Profile: "woff2", Style: "normal", Weight: AxisPoint(400), Format: "woff2", URL: "a", Version: "v1", SHA256: "1"},
+		{Family: "Domine", Profile: "woff2", Style: "normal", Weight: AxisPoint(700), Format: "woff2", URL: "b", Version: "v1", SHA256: "2"},
+	}}
+	upstream := &Lock{Fonts: []LockEntry{
+		{Family: "Domine", Profile: "woff2", Style: "normal", Weight: AxisPoint(400), Format: "woff2", URL: "a2", Version: "v1", SHA256: "1"},
+		{Family: "Domine", Profile: "woff2", Style: "italic", Weight: AxisPoint(400), Format: "woff2", URL: "c", Version: "v1", SHA256: "3"},
+	}}
+
+	drifts, err := locked.Diff(upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, d := range drifts {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"added woff2/Domine/italic/400/woff2: c",
+		"changed woff2/Domine/normal/400/woff2: url a -> a2",
+		"removed woff2/Domine/normal/700/woff2: b",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+
+	if drifts, err := locked.Diff(locked); err != nil || len(drifts) != 0 {
+		t.Errorf("got %v, %v, want no drift", drifts, err)
+	}
+
+	upstream.Fonts = append(upstream.Fonts, upstream.Fonts[0])
+	if _, err := locked.Diff(upstream); err == nil {
+		t.Errorf("expect error for two upstream fonts with the same key")
+	}
+}
+
+func TestLockStretch(t *testing.T) {
+	fonts := map[string]string{"/s/roboto/v30/condensed.woff2": "c", "/s/roboto/v30/normal.woff2": "n"}
+	host := ""
+	c, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/css2" {
+			w.Write([]byte(fonts[r.URL.Path]))
+			return
+		}
+		for _, width := range []string{"condensed", "normal"} {
+			w.Write([]byte("/* latin */\n@font-face {\n  font-family: 'Roboto';\n  font-style: normal;\n  font-weight: 400;\n" +
+				"  font-stretch: " + width + ";\n  src: url(" + host + "/s/roboto/v30/" + width + ".woff2) format('woff2');\n" +
+				"  unicode-range: U+0000-00FF;\n}\n"))
+		}
+	})
+	host = srv.URL
+	q := NewQuery().Family("Roboto", AxisWidth).Tuple(AxisPoint(75)).Tuple(AxisPoint(100))
+
+	lock, err := c.CreateLock(context.Background(), q, WOFF2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Fonts) != 2 || lock.Fonts[0].Stretch == nil || *lock.Fonts[0].Stretch != AxisPoint(75) || lock.Fonts[0].Subset != "latin" {
+		t.Fatalf("got %+v", lock.Fonts)
+	}
+	if lock.Fonts[0].key() == lock.Fonts[1].key() {
+		t.Errorf("fonts that differ by width have the same key %s", lock.Fonts[0].key())
+	}
+
+	// only the condensed font changed
+	fonts["/s/roboto/v30/condensed.woff2"] = "c2"
+	drifts, err := c.VerifyLock(context.Background(), lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 1 || drifts[0].Locked.URL != lock.Fonts[0].URL {
+		t.Errorf("got %v", drifts)
+	}
+}
+
+func TestLockDuplicateFamily(t *testing.T) {
+	c := newLockServer(t, map[string]string{"/s/domine/v20/a.woff2": "a"}, "/s/domine/v20/a.woff2")
+
+	q := NewQuery().Family("Domine", AxisWeight).Tuple(AxisPoint(400)).Family("Domine", AxisWeight).Tuple(AxisPoint(700))
+	if _, err := c.CreateLock(context.Background(), q, WOFF2); err == nil {
+		t.Errorf("expect error for a family requested with two specs")
+	}
+
+	q = NewQuery().Family("Domine", AxisWeight).Tuple(AxisPoint(400)).Family("Domine", AxisWeight).Tuple(AxisPoint(400))
+	lock, err := c.CreateLock(context.Background(), q, WOFF2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Fonts[0].Spec != "wght@400" {
+		t.Errorf("got spec %s", lock.Fonts[0].Spec)
+	}
+}
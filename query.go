@@ -0,0 +1,472 @@
+package gfont
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Registered axis tags understood by the css2 API. Custom axes use 4 uppercase letters or digits.
+const (
+	AxisItalic      = "ital"
+	AxisWeight      = "wght"
+	AxisWidth       = "wdth"
+	AxisOpticalSize = "opsz"
+	AxisSlant       = "slnt"
+)
+
+var registeredAxes = map[string][2]float64{
+	AxisItalic:      {0, 1},
+	AxisWeight:      {1, 1000},
+	AxisWidth:       {25, 200},
+	AxisOpticalSize: {0, 1000},
+	AxisSlant:       {-90, 90},
+}
+
+var displayValues = []string{"auto", "block", "swap", "fallback", "optional"}
+
+// AxisValue is a single value or a range of values of a font axis
+type AxisValue struct {
+	Min float64
+	Max float64
+}
+
+// AxisPoint returns an AxisValue for a single value
+func AxisPoint(v float64) AxisValue {
+	return AxisValue{Min: v, Max: v}
+}
+
+// AxisRange returns an AxisValue for the range min..max
+func AxisRange(min, max float64) AxisValue {
+	return AxisValue{Min: min, Max: max}
+}
+
+// IsRange reports whether v spans more than a single value
+func (v AxisValue) IsRange() bool {
+	return v.Min != v.Max
+}
+
+// String returns the css2 representation, such as 400 or 100..900
+func (v AxisValue) String() string {
+	if !v.IsRange() {
+		return formatAxisNumber(v.Min)
+	}
+	return formatAxisNumber(v.Min) + ".." + formatAxisNumber(v.Max)
+}
+
+// Contains reports whether x is within v
+func (v AxisValue) Contains(x float64) bool {
+	return x >= v.Min && x <= v.Max
+}
+
+// MarshalJSON returns a number for a single value, and [min, max] for a range
+func (v AxisValue) MarshalJSON() ([]byte, error) {
+	if !v.IsRange() {
+		return json.Marshal(v.Min)
+	}
+	return json.Marshal([2]float64{v.Min, v.Max})
+}
+
+// UnmarshalJSON accepts a number or [min, max]
+func (v *AxisValue) UnmarshalJSON(data []byte) error {
+	var bounds [2]float64
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &bounds); err != nil {
+			return err
+		}
+		*v = AxisRange(bounds[0], bounds[1])
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &bounds[0]); err != nil {
+		return err
+	}
+	*v = AxisPoint(bounds[0])
+	return nil
+}
+
+// Tuple is one value for each axis of a FamilySpec, in the same order as FamilySpec.Axes
+type Tuple []AxisValue
+
+// FamilySpec is a font family and the axis tuples requested for it
+type FamilySpec struct {
+	Family string
+	Axes   []string
+	Tuples []Tuple
+}
+
+// ParseFamilySpec parses a css2 style spec such as "wght@400;500" or "ital,wght@0,400;1,700" for a family.
+// An empty spec requests the default style.
+func ParseFamilySpec(family, spec string) (FamilySpec, error) {
+	result := FamilySpec{Family: family}
+	if spec == "" {
+		return result, nil
+	}
+
+	parts := strings.SplitN(spec, "@", 2)
+	if len(parts) != 2 {
+		return result, fmt.Errorf("expect <axes>@<tuples> in %q", spec)
+	}
+
+	result.Axes = strings.Split(parts[0], ",")
+	for _, rawTuple := range strings.Split(parts[1], ";") {
+		if rawTuple == "" {
+			continue
+		}
+
+		tuple := Tuple{}
+		for _, rawValue := range strings.Split(rawTuple, ",") {
+			v, err := parseAxisValue(rawValue)
+			if err != nil {
+				return result, fmt.Errorf("parse tuple %q failed: %v", rawTuple, err)
+			}
+			tuple = append(tuple, v)
+		}
+		result.Tuples = append(result.Tuples, tuple)
+	}
+
+	return result, nil
+}
+
+// Validate checks the family spec against the css2 rules
+func (fs FamilySpec) Validate() error {
+	if strings.TrimSpace(fs.Family) == "" {
+		return fmt.Errorf("family name cannot be empty")
+	}
+	if len(fs.Axes) == 0 {
+		if len(fs.Tuples) > 0 {
+			return fmt.Errorf("family %s: tuples without axes", fs.Family)
+		}
+		return nil
+	}
+	if len(fs.Tuples) == 0 {
+		return fmt.Errorf("family %s: axes without tuples", fs.Family)
+	}
+
+	seen := map[string]bool{}
+	for _, tag := range fs.Axes {
+		if err := validateAxisTag(tag); err != nil {
+			return fmt.Errorf("family %s: %v", fs.Family, err)
+		}
+		if seen[tag] {
+			return fmt.Errorf("family %s: duplicated axis %s", fs.Family, tag)
+		}
+		seen[tag] = true
+	}
+
+	for _, tuple := range fs.Tuples {
+		if len(tuple) != len(fs.Axes) {
+			return fmt.Errorf("family %s: tuple %s has %d values for %d axes", fs.Family, tuple, len(tuple), len(fs.Axes))
+		}
+		for i, v := range tuple {
+			if !isFinite(v.Min) || !isFinite(v.Max) {
+				return fmt.Errorf("family %s: axis %s value %s is not a finite number", fs.Family, fs.Axes[i], v)
+			}
+			if v.Min > v.Max {
+				return fmt.Errorf("family %s: axis %s range %s is reversed", fs.Family, fs.Axes[i], v)
+			}
+			bounds, ok := registeredAxes[fs.Axes[i]]
+			if !ok {
+				continue
+			}
+			if v.Min < bounds[0] || v.Max > bounds[1] {
+				return fmt.Errorf("family %s: axis %s value %s out of range %s..%s", fs.Family, fs.Axes[i], v,
+					formatAxisNumber(bounds[0]), formatAxisNumber(bounds[1]))
+			}
+			if fs.Axes[i] == AxisItalic && (v.IsRange() || (v.Min != 0 && v.Min != 1)) {
+				return fmt.Errorf("family %s: axis %s accepts 0 or 1 only", fs.Family, AxisItalic)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Spec returns the canonical css2 style spec, with axes and tuples sorted the way css2 requires
+func (fs FamilySpec) Spec() string {
+	if len(fs.Axes) == 0 {
+		return ""
+	}
+
+	// lowercase (registered) axes come before uppercase (custom) ones, each group sorted alphabetically
+	order := make([]int, len(fs.Axes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return axisLess(fs.Axes[order[i]], fs.Axes[order[j]])
+	})
+
+	axes := make([]string, len(order))
+	for i, o := range order {
+		axes[i] = fs.Axes[o]
+	}
+
+	tuples := make([]Tuple, 0, len(fs.Tuples))
+	for _, t := range fs.Tuples {
+		if len(t) != len(order) {
+			continue
+		}
+		sorted := make(Tuple, len(order))
+		for i, o := range order {
+			sorted[i] = t[o]
+		}
+		tuples = append(tuples, sorted)
+	}
+	sort.SliceStable(tuples, func(i, j int) bool {
+		return tupleLess(tuples[i], tuples[j])
+	})
+
+	rawTuples := []string{}
+	for i, t := range tuples {
+		if i > 0 && t.String() == tuples[i-1].String() {
+			continue
+		}
+		rawTuples = append(rawTuples, t.String())
+	}
+
+	return strings.Join(axes, ",") + "@" + strings.Join(rawTuples, ";")
+}
+
+// String returns the css2 family parameter value, such as Roboto:ital,wght@0,400
+func (fs FamilySpec) String() string {
+	name := url.QueryEscape(fs.Family)
+	spec := fs.Spec()
+	if spec == "" {
+		return name
+	}
+	return name + ":" + spec
+}
+
+// String returns the css2 representation of a tuple, such as 0,100..900
+func (t Tuple) String() string {
+	parts := make([]string, len(t))
+	for i, v := range t {
+		parts[i] = v.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Query is a css2 API request for one or more font families
+type Query struct {
+	Families []FamilySpec
+	// Display is the font-display strategy, such as swap
+	Display string
+	// Text restricts the fonts to the characters in Text
+	Text string
+}
+
+// NewQuery returns an empty Query
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// Family appends a family with the given axis tags. Use Tuple to add values for the axes.
+func (q *Query) Family(family string, axes ...string) *Query {
+	q.Families = append(q.Families, FamilySpec{Family: family, Axes: axes})
+	return q
+}
+
+// Add appends family specs, such as those returned by ParseFamilySpec
+func (q *Query) Add(specs ...FamilySpec) *Query {
+	q.Families = append(q.Families, specs...)
+	return q
+}
+
+// Tuple appends a tuple of axis values to the last family added
+func (q *Query) Tuple(values ...AxisValue) *Query {
+	if len(q.Families) == 0 {
+		// reported by Validate
+		q.Families = append(q.Families, FamilySpec{})
+	}
+	last := &q.Families[len(q.Families)-1]
+	last.Tuples = append(last.Tuples, Tuple(values))
+	return q
+}
+
+// SetDisplay sets the font-display strategy
+func (q *Query) SetDisplay(display string) *Query {
+	q.Display = display
+	return q
+}
+
+// SetText restricts the fonts to the characters in text
+func (q *Query) SetText(text string) *Query {
+	q.Text = text
+	return q
+}
+
+// Validate checks the query locally so that mistakes do not show up as server errors
+func (q *Query) Validate() error {
+	if len(q.Families) == 0 {
+		return fmt.Errorf("query must have at least one family")
+	}
+	for _, fs := range q.Families {
+		if err := fs.Validate(); err != nil {
+			return err
+		}
+	}
+	if q.Display != "" && isUniqueString(displayValues, q.Display) {
+		return fmt.Errorf("unsupported display %s", q.Display)
+	}
+	if !utf8.ValidString(q.Text) {
+		return fmt.Errorf("text is not valid UTF-8")
+	}
+	return nil
+}
+
+// Encode returns the canonical css2 query string, without the leading ?
+func (q *Query) Encode() string {
+	params := []string{}
+	for _, fs := range q.Families {
+		params = append(params, "family="+fs.String())
+	}
+	if q.Display != "" {
+		params = append(params, "display="+url.QueryEscape(q.Display))
+	}
+	if q.Text != "" {
+		// spaces as %20 rather than +, as in the css2 documentation
+		params = append(params, "text="+strings.Replace(url.QueryEscape(q.Text), "+", "%20", -1))
+	}
+	return strings.Join(params, "&")
+}
+
+// URL returns the canonical css2 URL using base as the endpoint. The Google API is used if base is empty.
+func (q *Query) URL(base string) string {
+	if base == "" {
+		base = apiBaseURL
+	}
+	return base + "?" + q.Encode()
+}
+
+// ParseQuery parses a css2 URL, or just its query string, into a Query
+func ParseQuery(rawURL string) (*Query, error) {
+	rawQuery := rawURL
+	if i := strings.Index(rawURL, "?"); i >= 0 {
+		rawQuery = rawURL[i+1:]
+	}
+	if i := strings.Index(rawQuery, "#"); i >= 0 {
+		rawQuery = rawQuery[:i]
+	}
+
+	// url.ParseQuery rejects the semicolons used by css2 tuples, so split by hand
+	q := NewQuery()
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+
+		kv := strings.SplitN(param, "=", 2)
+		key := kv[0]
+		value := ""
+		if len(kv) == 2 {
+			var err error
+			value, err = url.QueryUnescape(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("parse %s failed: %v", key, err)
+			}
+		}
+
+		switch key {
+		case "family":
+			nameSpec := strings.SplitN(value, ":", 2)
+			spec := ""
+			if len(nameSpec) == 2 {
+				spec = nameSpec[1]
+			}
+			fs, err := ParseFamilySpec(nameSpec[0], spec)
+			if err != nil {
+				return nil, err
+			}
+			q.Families = append(q.Families, fs)
+		case "display":
+			q.Display = value
+		case "text":
+			q.Text = value
+		}
+	}
+
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// --- helpers ---
+
+func validateAxisTag(tag string) error {
+	if _, ok := registeredAxes[tag]; ok {
+		return nil
+	}
+	if len(tag) != 4 {
+		return fmt.Errorf("axis tag %q must have 4 characters", tag)
+	}
+	for _, r := range tag {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return fmt.Errorf("unknown axis %q (custom axes use uppercase tags)", tag)
+		}
+	}
+	return nil
+}
+
+func axisLess(a, b string) bool {
+	aCustom := strings.ToLower(a) != a
+	bCustom := strings.ToLower(b) != b
+	if aCustom != bCustom {
+		return bCustom
+	}
+	return a < b
+}
+
+func tupleLess(a, b Tuple) bool {
+	for i := range a {
+		if a[i].Min != b[i].Min {
+			return a[i].Min < b[i].Min
+		}
+		if a[i].Max != b[i].Max {
+			return a[i].Max < b[i].Max
+		}
+	}
+	return false
+}
+
+func parseAxisValue(s string) (AxisValue, error) {
+	parts := strings.SplitN(s, "..", 2)
+	min, err := parseAxisNumber(parts[0])
+	if err != nil {
+		return AxisValue{}, err
+	}
+	if len(parts) == 1 {
+		return AxisPoint(min), nil
+	}
+	max, err := parseAxisNumber(parts[1])
+	if err != nil {
+		return AxisValue{}, err
+	}
+	return AxisRange(min, max), nil
+}
+
+// parseAxisNumber parses a number, rejecting the NaN and Inf accepted by strconv.ParseFloat
+func parseAxisNumber(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if !isFinite(v) {
+		return 0, fmt.Errorf("%s is not a finite number", s)
+	}
+	return v, nil
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func formatAxisNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,162 @@
+package gfont
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFamilySpecValidate(t *testing.T) {
+	tests := []struct {
+		spec  FamilySpec
+		valid bool
+	}{
+		{FamilySpec{Family: "Domine"}, true},
+		{FamilySpec{Family: "Domine", Axes: []string{AxisWeight}, Tuples: []Tuple{{AxisPoint(400)}}}, true},
+		{FamilySpec{Family: "Roboto Flex", Axes: []string{"GRAD", AxisWeight}, Tuples: []Tuple{{AxisRange(-200, 150), AxisRange(100, 1000)}}}, true},
+		{FamilySpec{Family: " "}, false},
+		{FamilySpec{Family: "Domine", Axes: []string{AxisWeight}}, false},
+		{FamilySpec{Family: "Domine", Tuples: []Tuple{{AxisPoint(400)}}}, false},
+		{FamilySpec{Family: "Domine", Axes: []string{"grad"}, Tuples: []Tuple{{AxisPoint(0)}}}, false},
+		{FamilySpec{Family: "Domine", Axes: []string{"GRADE"}, Tuples: []Tuple{{AxisPoint(0)}}}, false},
+		{FamilySpec{Family: "Domine", Axes: []string{AxisWeight, AxisWeight}, Tuples: []Tuple{{AxisPoint(400), AxisPoint(400)}}}, false},
+		{FamilySpec{Family: "Domine", Axes: []string{AxisItalic, AxisWeight}, Tuples: []Tuple{{AxisPoint(400)}}}, false},
+		{FamilySpec{Family: "Domine", Axes: []string{AxisWeight}, Tuples: []Tuple{{AxisRange(900, 100)}}}, false},
+		{FamilySpec{Family: "Domine", Axes: []string{AxisWeight}, Tuples: []Tuple{{AxisPoint(1001)}}}, false},
+		{FamilySpec{Family: "Domine", Axes: []string{AxisItalic}, Tuples: []Tuple{{AxisPoint(0.5)}}}, false},
+		{FamilySpec{Family: "Domine", Axes: []string{AxisItalic}, Tuples: []Tuple{{AxisRange(0, 1)}}}, false},
+		{FamilySpec{Family: "Domine", Axes: []string{AxisWeight}, Tuples: []Tuple{{AxisPoint(math.NaN())}}}, false},
+		{FamilySpec{Family: "Domine", Axes: []string{AxisWeight}, Tuples: []Tuple{{AxisRange(100, math.Inf(1))}}}, false},
+		{FamilySpec{Family: "Roboto Flex", Axes: []string{"GRAD"}, Tuples: []Tuple{{AxisRange(math.Inf(-1), 0)}}}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.spec.Validate()
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: got %v, want valid %v", tt.spec, err, tt.valid)
+		}
+	}
+}
+
+func TestQueryValidate(t *testing.T) {
+	if err := NewQuery().Validate(); err == nil {
+		t.Errorf("expect error for a query without family")
+	}
+	if err := NewQuery().Tuple(AxisPoint(400)).Validate(); err == nil {
+		t.Errorf("expect error for a tuple without family")
+	}
+	if err := NewQuery().Family("Domine").SetDisplay("sometimes").Validate(); err == nil {
+		t.Errorf("expect error for an unsupported display")
+	}
+	if err := NewQuery().Family("Domine").SetText("\xff").Validate(); err == nil {
+		t.Errorf("expect error for invalid UTF-8 text")
+	}
+	if err := NewQuery().Family("Domine").SetDisplay("swap").SetText("abc").Validate(); err != nil {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestFamilySpecSort(t *testing.T) {
+	fs := FamilySpec{
+		Family: "Roboto Flex",
+		Axes:   []string{AxisWeight, "GRAD", AxisItalic},
+		Tuples: []Tuple{
+			{AxisPoint(700), AxisPoint(0), AxisPoint(1)},
+			{AxisRange(100, 900), AxisPoint(0), AxisPoint(0)},
+			{AxisPoint(400), AxisPoint(0), AxisPoint(1)},
+			{AxisPoint(700), AxisPoint(0), AxisPoint(1)},
+		},
+	}
+	if got, want := fs.Spec(), "ital,wght,GRAD@0,100..900,0;1,400,0;1,700,0"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got, want := fs.String(), "Roboto+Flex:ital,wght,GRAD@0,100..900,0;1,400,0;1,700,0"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got := (FamilySpec{Family: "Open Sans"}).String(); got != "Open+Sans" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestParseFamilySpec(t *testing.T) {
+	fs, err := ParseFamilySpec("Domine", "ital,wght@0,400;1,100..900")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.Axes) != 2 || len(fs.Tuples) != 2 || fs.Tuples[1][1] != AxisRange(100, 900) {
+		t.Errorf("got %+v", fs)
+	}
+
+	for _, bad := range []string{"wght", "wght@bold", "wght@100..x", "wght@NaN", "wght@100..Inf", "GRAD@-inf..0"} {
+		if _, err := ParseFamilySpec("Domine", bad); err == nil {
+			t.Errorf("%s: expect error", bad)
+		}
+	}
+}
+
+func TestParseQueryRoundTrip(t *testing.T) {
+	q := NewQuery().
+		Family("Open Sans", AxisWeight, AxisItalic).Tuple(AxisRange(300, 800), AxisPoint(1)).Tuple(AxisPoint(400), AxisPoint(0)).
+		SetDisplay("swap")
+	want := "family=Open+Sans:ital,wght@0,400;1,300..800&display=swap"
+	if got := q.Encode(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	parsed, err := ParseQuery(q.URL("https://example.com/css2") + "#top")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Encode(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if !strings.HasPrefix(q.URL(""), apiBaseURL+"?") {
+		t.Errorf("got %s", q.URL(""))
+	}
+
+	for _, bad := range []string{"family=Domine:wght@2000", "family=Domine:wght@NaN", "family=Domine:wght@%2BInf"} {
+		if _, err := ParseQuery(bad); err == nil {
+			t.Errorf("%s: expect ParseQuery to validate the query", bad)
+		}
+	}
+}
+
+func TestQueryMultipleFamilies(t *testing.T) {
+	q := NewQuery().
+		Family("Roboto", AxisWeight).Tuple(AxisPoint(700)).Tuple(AxisPoint(400)).
+		Family("Open Sans").
+		Add(FamilySpec{Family: "Domine", Axes: []string{AxisWeight}, Tuples: []Tuple{{AxisRange(400, 700)}}}).
+		SetText("Hi")
+	want := "family=Roboto:wght@400;700&family=Open+Sans&family=Domine:wght@400..700&text=Hi"
+	if got := q.Encode(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	parsed, err := ParseQuery(q.URL(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Families) != 3 || parsed.Families[1].Family != "Open Sans" {
+		t.Errorf("got %+v", parsed.Families)
+	}
+	if got := parsed.Encode(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	var gotQuery string
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+	})
+	if _, err := c.Download(context.Background(), TTF, q); err != nil {
+		t.Fatal(err)
+	}
+	if gotQuery != want {
+		t.Errorf("got query %s, want %s", gotQuery, want)
+	}
+
+	if _, err := c.Download(context.Background(), TTF, NewQuery().Family("Domine").Family("")); err == nil {
+		t.Errorf("expect Download to validate every family")
+	}
+}
@@ -0,0 +1,175 @@
+package gfont
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// timeoutError is a net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://fonts.googleapis.com/css2", Err: err}
+	}
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&APIError{Kind: ErrRateLimited}, true},
+		{&APIError{Kind: ErrServer}, true},
+		{&APIError{Kind: ErrUnknownFamily}, false},
+		{&APIError{Kind: ErrHTTPStatus}, false},
+		{urlErr(timeoutError{}), true},
+		{urlErr(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), true},
+		{urlErr(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), true},
+		{urlErr(errors.New(`unsupported protocol scheme "ftp"`)), false},
+		{urlErr(x509.HostnameError{Host: "example.com", Certificate: &x509.Certificate{}}), false},
+		{urlErr(context.Canceled), false},
+		{context.DeadlineExceeded, false},
+	}
+
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryBadScheme(t *testing.T) {
+	transport := &countingTransport{}
+	base, _ := url.Parse("ftp://fonts.example.com/css2")
+	c := &Client{
+		HTTPClient: &http.Client{Transport: transport},
+		BaseURL:    base,
+		Retry:      &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour},
+	}
+
+	if _, err := c.DownloadCSS(context.Background(), TTF, "Domine", ""); err == nil {
+		t.Fatal("expect error for an unsupported scheme")
+	}
+	if transport.requests != 1 {
+		t.Errorf("got %d attempts, want 1", transport.requests)
+	}
+}
+
+func TestRetryConnectionRefused(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close()
+
+	transport := &countingTransport{}
+	base, _ := url.Parse(srv.URL)
+	c := &Client{
+		HTTPClient: &http.Client{Transport: transport},
+		BaseURL:    base,
+		Retry:      &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+	}
+
+	if _, err := c.DownloadCSS(context.Background(), TTF, "Domine", ""); err == nil {
+		t.Fatal("expect error for a closed server")
+	}
+	if transport.requests != 2 {
+		t.Errorf("got %d attempts, want 2", transport.requests)
+	}
+}
+
+func TestRetryServerError(t *testing.T) {
+	requests := 0
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte("body"))
+		}
+	})
+	c.Retry = &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	body, err := c.DownloadCSS(context.Background(), TTF, "Domine", "")
+	if err != nil || string(body) != "body" || requests != 3 {
+		t.Errorf("got %q, %v after %d requests", body, err, requests)
+	}
+
+	requests = 0
+	c.Retry.MaxAttempts = 2
+	if _, err := c.DownloadCSS(context.Background(), TTF, "Domine", ""); !errors.Is(err, ErrRateLimited) || requests != 2 {
+		t.Errorf("got %v after %d requests, want ErrRateLimited after 2", err, requests)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second}
+	if got := p.delay(1, errors.New("x")); got != 100*time.Millisecond {
+		t.Errorf("attempt 1: got %v", got)
+	}
+	if got := p.delay(3, errors.New("x")); got != 400*time.Millisecond {
+		t.Errorf("attempt 3: got %v", got)
+	}
+	if got := p.delay(1, &APIError{Kind: ErrRateLimited, RetryAfter: 2 * time.Second}); got != 2*time.Second {
+		t.Errorf("retry-after: got %v", got)
+	}
+	if got := p.delay(1, &APIError{Kind: ErrRateLimited, RetryAfter: time.Minute}); got != 5*time.Second {
+		t.Errorf("retry-after over MaxDelay: got %v", got)
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		if got := p.delay(1, errors.New("x")); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("jitter: got %v", got)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("120"); got != 2*time.Minute {
+		t.Errorf("got %v", got)
+	}
+	if got := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("got %v", got)
+	}
+	if got := parseRetryAfter("soon"); got != 0 {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(50, 2)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 2 requests in the burst, then 2 more at 20ms each
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("4 requests took %v, want at least 40ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewRateLimiter(0.001, 1).Wait(ctx); err == nil {
+		t.Errorf("expect error for a canceled context")
+	}
+
+	var nilLimiter *RateLimiter
+	if err := nilLimiter.Wait(context.Background()); err != nil {
+		t.Errorf("nil limiter: got %v", err)
+	}
+}
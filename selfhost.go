@@ -0,0 +1,60 @@
+package gfont
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// SelfHost downloads every font file in ts with d, and returns a copy of ts with each URL rewritten to point to the
+// downloaded file. baseURL is prepended to the path of each file, such as /static/fonts or https://cdn.example.com/fonts.
+// If baseURL is empty, the URLs are relative to the download directory, which suits a CSS file saved in that directory.
+func SelfHost(ctx context.Context, d *Downloader, ts *Typefaces, baseURL string) (*Typefaces, *Manifest, error) {
+	manifest, err := d.Download(ctx, ts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &Typefaces{Fonts: make([]Typeface, len(ts.Fonts))}
+	for i, t := range ts.Fonts {
+		sources := []Source{}
+		for _, src := range t.AllSources() {
+			if !src.IsLocal() {
+				local := t.withSource(src)
+				relPath, err := d.Path(&local)
+				if err != nil {
+					return nil, nil, err
+				}
+				u, err := localURL(baseURL, relPath)
+				if err != nil {
+					return nil, nil, err
+				}
+				// SVG fonts select the font by fragment id
+				u.Fragment = src.URL.Fragment
+				src.URL = u
+			}
+			sources = append(sources, src)
+		}
+
+		result.Fonts[i] = t
+		result.Fonts[i].URL = nil
+		for _, src := range sources {
+			if !src.IsLocal() {
+				result.Fonts[i].URL = src.URL
+				break
+			}
+		}
+		if len(t.Sources) > 0 {
+			result.Fonts[i].Sources = sources
+		}
+	}
+
+	return result, manifest, nil
+}
+
+func localURL(baseURL, relPath string) (*url.URL, error) {
+	if baseURL == "" {
+		return url.Parse(relPath)
+	}
+	return url.Parse(strings.TrimSuffix(baseURL, "/") + "/" + relPath)
+}
@@ -0,0 +1,73 @@
+package gfont
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSelfHost(t *testing.T) {
+	srv := newFontServer(t)
+	ts := &Typefaces{}
+	css := `@font-face {
+  font-family: 'Open Sans';
+  font-style: normal;
+  font-weight: 400;
+  src: local('Open Sans'), url(` + srv.URL + `/s/opensans/v18/a.woff2) format('woff2'),
+       url(` + srv.URL + `/s/opensans/v18/a.ttf) format('truetype');
+}
+@font-face {
+  font-family: 'Domine';
+  font-style: normal;
+  font-weight: 400;
+  src: url(` + srv.URL + `/l/font?kit=L0x8DFMnlVwD4h3&skey=ea73fc1e1d1dfd9a&v=v20#Domine) format('svg');
+}
+`
+	if err := UnmarshalCSS([]byte(css), ts); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		baseURL string
+		want    []string
+	}{
+		{"", []string{"opensans/v18/a.woff2", "opensans/v18/a.ttf", "domine/v20/L0x8DFMnlVwD4h3.svg#Domine"}},
+		{"/static/fonts/", []string{"/static/fonts/opensans/v18/a.woff2", "/static/fonts/opensans/v18/a.ttf", "/static/fonts/domine/v20/L0x8DFMnlVwD4h3.svg#Domine"}},
+		{"https://cdn.example.com/fonts", []string{"https://cdn.example.com/fonts/opensans/v18/a.woff2", "https://cdn.example.com/fonts/opensans/v18/a.ttf", "https://cdn.example.com/fonts/domine/v20/L0x8DFMnlVwD4h3.svg#Domine"}},
+	}
+
+	for _, tt := range tests {
+		d := NewDownloader(t.TempDir())
+		hosted, manifest, err := SelfHost(context.Background(), d, ts, tt.baseURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(manifest.Files) != 3 {
+			t.Errorf("%q: got %d files, want 3", tt.baseURL, len(manifest.Files))
+		}
+
+		got := []string{}
+		for _, f := range hosted.Fonts {
+			for _, src := range f.AllSources() {
+				if !src.IsLocal() {
+					got = append(got, src.URL.String())
+				}
+			}
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%q: got %v, want %v", tt.baseURL, got, tt.want)
+		}
+
+		openSans := hosted.Fonts[0]
+		if openSans.URL.String() != tt.want[0] || len(openSans.Sources) != 3 || !openSans.Sources[0].IsLocal() {
+			t.Errorf("%q: got %s %+v", tt.baseURL, openSans.URL, openSans.Sources)
+		}
+		if css := openSans.CSS(); !strings.Contains(css, "local(") {
+			t.Errorf("%q: local source lost in %s", tt.baseURL, css)
+		}
+	}
+
+	if !strings.HasPrefix(ts.Fonts[0].URL.String(), srv.URL) {
+		t.Errorf("SelfHost changed the input typefaces: %s", ts.Fonts[0].URL)
+	}
+}
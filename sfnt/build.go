@@ -0,0 +1,109 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrChecksum is returned when a table does not match its checksum
+var ErrChecksum = errors.New("checksum mismatch")
+
+// checksumMagic is the value that the checksum of a whole font adds up to with the checksum adjustment of head
+const checksumMagic = 0xB1B0AFBA
+
+// Build assembles tables into a font file. version is the sfnt version, such as TrueType. Tables are sorted by tag and
+// padded to 4 bytes, and their checksums are computed, as is the checksum adjustment of head.
+func Build(version uint32, tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	numTables := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+	if numTables == 0 {
+		searchRange = 0
+	}
+
+	size := 12 + 16*numTables
+	for _, tag := range tags {
+		size += pad4(len(tables[tag]))
+	}
+	out := make([]byte, 12+16*numTables, size)
+	binary.BigEndian.PutUint32(out[0:], version)
+	binary.BigEndian.PutUint16(out[4:], uint16(numTables))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(16*numTables-searchRange))
+
+	headOffset := -1
+	for i, tag := range tags {
+		data := tables[tag]
+		start := len(out)
+		out = append(out, data...)
+		out = append(out, make([]byte, pad4(len(data))-len(data))...)
+		if tag == "head" && len(data) >= 12 {
+			// the checksum of head is computed with a zero checksum adjustment
+			headOffset = start
+			binary.BigEndian.PutUint32(out[start+8:], 0)
+		}
+
+		p := 12 + 16*i
+		copy(out[p:p+4], tag)
+		binary.BigEndian.PutUint32(out[p+4:], checksum(out[start:]))
+		binary.BigEndian.PutUint32(out[p+8:], uint32(start))
+		binary.BigEndian.PutUint32(out[p+12:], uint32(len(data)))
+	}
+
+	if headOffset >= 0 {
+		binary.BigEndian.PutUint32(out[headOffset+8:], checksumMagic-checksum(out))
+	}
+	return out
+}
+
+// VerifyChecksums checks the checksum of each table in the table directory
+func (f *Font) VerifyChecksums() error {
+	for _, tr := range f.Tables {
+		data, _ := f.Table(tr.Tag)
+		if sum := tableChecksum(tr.Tag, data); sum != tr.Checksum {
+			return fmt.Errorf("%w: table %s has checksum %08x, want %08x", ErrChecksum, tr.Tag, sum, tr.Checksum)
+		}
+	}
+	return nil
+}
+
+// tableChecksum returns the checksum of a table. The checksum adjustment of head is not part of it.
+func tableChecksum(tag string, data []byte) uint32 {
+	sum := checksum(data)
+	if tag == "head" && len(data) >= 12 {
+		sum -= u32(data, 8)
+	}
+	return sum
+}
+
+// checksum adds up data as big endian uint32 values, padding it with zeros
+func checksum(data []byte) uint32 {
+	var sum uint32
+	n := len(data) &^ 3
+	for i := 0; i < n; i += 4 {
+		sum += u32(data, i)
+	}
+	if n < len(data) {
+		var last [4]byte
+		copy(last[:], data[n:])
+		sum += binary.BigEndian.Uint32(last[:])
+	}
+	return sum
+}
+
+// pad4 rounds n up to a multiple of 4
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
@@ -0,0 +1,179 @@
+package sfnt
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GlyphID is the index of a glyph
+type GlyphID uint16
+
+// Cmap maps characters to glyphs. It is decoded from the best Unicode subtable of the cmap table.
+type Cmap struct {
+	PlatformID uint16 `json:"platformId"`
+	EncodingID uint16 `json:"encodingId"`
+	// Format is the subtable format: 4 for the Basic Multilingual Plane only, 12 for all planes
+	Format uint16 `json:"format"`
+	glyphs map[rune]GlyphID
+}
+
+// Lookup returns the glyph of r, or false if the font has none
+func (c *Cmap) Lookup(r rune) (GlyphID, bool) {
+	g, ok := c.glyphs[r]
+	return g, ok
+}
+
+// Len returns the number of characters mapped
+func (c *Cmap) Len() int {
+	return len(c.glyphs)
+}
+
+// Runes returns the characters mapped to a glyph, in order
+func (c *Cmap) Runes() []rune {
+	result := make([]rune, 0, len(c.glyphs))
+	for r := range c.glyphs {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
+// maxCmapRunes caps the number of characters expanded from the groups or segments of a subtable. Overlapping groups
+// could otherwise make a small cmap expand to billions of characters.
+const maxCmapRunes = 0x110000
+
+// cmapPreference lists the platform and encoding of Unicode subtables, best first
+var cmapPreference = []struct {
+	platformID, encodingID uint16
+}{
+	{3, 10}, // Windows, full repertoire
+	{0, 6},  // Unicode, full repertoire
+	{0, 4},  // Unicode 2.0, full repertoire
+	{3, 1},  // Windows, BMP
+	{0, 3},  // Unicode 2.0, BMP
+	{0, 2},
+	{0, 1},
+	{0, 0},
+	{3, 0}, // Windows, symbol, in U+F000-F0FF
+}
+
+// Cmap decodes the best Unicode subtable of the cmap table. Subtables of formats 4 and 12 are supported.
+func (f *Font) Cmap() (*Cmap, error) {
+	b, err := f.table("cmap", 4)
+	if err != nil {
+		return nil, err
+	}
+
+	numTables := int(u16(b, 2))
+	if len(b) < 4+8*numTables {
+		return nil, fmt.Errorf("%w: cmap records truncated", ErrFormat)
+	}
+
+	for _, pref := range cmapPreference {
+		for i := 0; i < numTables; i++ {
+			p := 4 + 8*i
+			if u16(b, p) != pref.platformID || u16(b, p+2) != pref.encodingID {
+				continue
+			}
+			offset := int(u32(b, p+4))
+			if offset+2 > len(b) {
+				return nil, fmt.Errorf("%w: cmap subtable out of bounds", ErrFormat)
+			}
+
+			c := &Cmap{PlatformID: pref.platformID, EncodingID: pref.encodingID, Format: u16(b, offset)}
+			switch c.Format {
+			case 4:
+				c.glyphs, err = parseCmap4(b[offset:])
+			case 12:
+				c.glyphs, err = parseCmap12(b[offset:])
+			default:
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no supported Unicode subtable in cmap", ErrNotFound)
+}
+
+// parseCmap4 decodes a segment mapping to delta values subtable
+func parseCmap4(b []byte) (map[rune]GlyphID, error) {
+	if len(b) < 14 {
+		return nil, fmt.Errorf("%w: cmap format 4 too short", ErrFormat)
+	}
+	segCount := int(u16(b, 6)) / 2
+	endCodes := 14
+	startCodes := endCodes + 2*segCount + 2
+	idDeltas := startCodes + 2*segCount
+	idRangeOffsets := idDeltas + 2*segCount
+	if idRangeOffsets+2*segCount > len(b) {
+		return nil, fmt.Errorf("%w: cmap format 4 segments truncated", ErrFormat)
+	}
+
+	result := map[rune]GlyphID{}
+	total := 0
+	for i := 0; i < segCount; i++ {
+		start, end := int(u16(b, startCodes+2*i)), int(u16(b, endCodes+2*i))
+		if end >= start {
+			if total += end - start + 1; total > maxCmapRunes {
+				return nil, fmt.Errorf("%w: cmap format 4 segments cover too many characters", ErrFormat)
+			}
+		}
+		delta := int(u16(b, idDeltas+2*i))
+		rangeOffset := int(u16(b, idRangeOffsets+2*i))
+		for c := start; c <= end && c != 0xFFFF; c++ {
+			var g int
+			if rangeOffset == 0 {
+				g = (c + delta) & 0xFFFF
+			} else {
+				// idRangeOffset is relative to its own position in the idRangeOffset array
+				p := idRangeOffsets + 2*i + rangeOffset + 2*(c-start)
+				if p+2 > len(b) {
+					return nil, fmt.Errorf("%w: cmap format 4 glyph index out of bounds", ErrFormat)
+				}
+				if g = int(u16(b, p)); g != 0 {
+					g = (g + delta) & 0xFFFF
+				}
+			}
+			if g != 0 {
+				result[rune(c)] = GlyphID(g)
+			}
+		}
+	}
+	return result, nil
+}
+
+// parseCmap12 decodes a segmented coverage subtable
+func parseCmap12(b []byte) (map[rune]GlyphID, error) {
+	if len(b) < 16 {
+		return nil, fmt.Errorf("%w: cmap format 12 too short", ErrFormat)
+	}
+	numGroups := int(u32(b, 12))
+	if numGroups < 0 || numGroups > (len(b)-16)/12 {
+		return nil, fmt.Errorf("%w: cmap format 12 groups truncated", ErrFormat)
+	}
+
+	result := map[rune]GlyphID{}
+	total := 0
+	for i := 0; i < numGroups; i++ {
+		p := 16 + 12*i
+		start, end, glyph := u32(b, p), u32(b, p+4), u32(b, p+8)
+		if start > end || end > 0x10FFFF {
+			return nil, fmt.Errorf("%w: bad cmap format 12 group", ErrFormat)
+		}
+		if total += int(end-start) + 1; total > maxCmapRunes {
+			return nil, fmt.Errorf("%w: cmap format 12 groups cover too many characters", ErrFormat)
+		}
+		for c := start; c <= end; c++ {
+			g := glyph + (c - start)
+			if g > 0 && g <= 0xFFFF {
+				result[rune(c)] = GlyphID(g)
+			}
+		}
+	}
+	return result, nil
+}
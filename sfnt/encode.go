@@ -0,0 +1,166 @@
+package sfnt
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+
+	"github.com/andybalholm/brotli"
+)
+
+// headFlagLossless is the flag of head set on fonts that went through a woff2 encoder
+const headFlagLossless = 1 << 11
+
+// EncodeWOFF encodes a font file as WOFF 1.0. Each table is compressed with zlib, unless that does not make it smaller.
+func EncodeWOFF(data []byte) ([]byte, error) {
+	f, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	major, minor, err := f.woffVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	numTables := len(f.Tables)
+	dir := make([]byte, 0, 20*numTables)
+	body := []byte{}
+	offset := 44 + 20*numTables
+	for _, tr := range f.Tables {
+		table, _ := f.Table(tr.Tag)
+		stored := zlibCompress(table)
+		if len(stored) >= len(table) {
+			stored = table
+		}
+
+		entry := make([]byte, 20)
+		copy(entry, tr.Tag)
+		binary.BigEndian.PutUint32(entry[4:], uint32(offset+len(body)))
+		binary.BigEndian.PutUint32(entry[8:], uint32(len(stored)))
+		binary.BigEndian.PutUint32(entry[12:], uint32(len(table)))
+		binary.BigEndian.PutUint32(entry[16:], tableChecksum(tr.Tag, table))
+		dir = append(dir, entry...)
+		body = append(body, stored...)
+		body = append(body, make([]byte, pad4(len(body))-len(body))...)
+	}
+
+	header := make([]byte, 44)
+	binary.BigEndian.PutUint32(header[0:], woffSignature)
+	binary.BigEndian.PutUint32(header[4:], f.Version)
+	binary.BigEndian.PutUint32(header[8:], uint32(offset+len(body)))
+	binary.BigEndian.PutUint16(header[12:], uint16(numTables))
+	binary.BigEndian.PutUint32(header[16:], uint32(f.sfntSize()))
+	binary.BigEndian.PutUint16(header[20:], major)
+	binary.BigEndian.PutUint16(header[22:], minor)
+
+	out := append(header, dir...)
+	return append(out, body...), nil
+}
+
+// EncodeWOFF2 encodes a font file as WOFF2. Tables are stored as is, glyf and loca with the null transform, and
+// compressed together with brotli.
+func EncodeWOFF2(data []byte) ([]byte, error) {
+	f, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	major, minor, err := f.woffVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	// loca follows glyf, the order decoders rebuild them in
+	tags := []string{}
+	for _, tr := range f.Tables {
+		if tr.Tag == "loca" && f.HasTable("glyf") {
+			continue
+		}
+		tags = append(tags, tr.Tag)
+		if tr.Tag == "glyf" && f.HasTable("loca") {
+			tags = append(tags, "loca")
+		}
+	}
+
+	knownTags := map[string]int{}
+	for i, tag := range woff2KnownTags {
+		knownTags[tag] = i
+	}
+
+	dir := []byte{}
+	stream := []byte{}
+	for _, tag := range tags {
+		table, _ := f.Table(tag)
+		if tag == "head" && len(table) >= 18 {
+			table = append([]byte{}, table...)
+			binary.BigEndian.PutUint16(table[16:], u16(table, 16)|headFlagLossless)
+		}
+
+		flags := woff2NullTransform(tag) << 6
+		if i, ok := knownTags[tag]; ok {
+			dir = append(dir, flags|byte(i))
+		} else {
+			dir = append(dir, flags|0x3F)
+			dir = append(dir, tag...)
+		}
+		dir = append(dir, base128(uint32(len(table)))...)
+		stream = append(stream, table...)
+	}
+
+	var compressed bytes.Buffer
+	bw := brotli.NewWriterOptions(&compressed, brotli.WriterOptions{Quality: brotli.BestCompression, LGWin: 22})
+	bw.Write(stream)
+	bw.Close()
+
+	header := make([]byte, 48)
+	length := pad4(len(header) + len(dir) + compressed.Len())
+	binary.BigEndian.PutUint32(header[0:], woff2Signature)
+	binary.BigEndian.PutUint32(header[4:], f.Version)
+	binary.BigEndian.PutUint32(header[8:], uint32(length))
+	binary.BigEndian.PutUint16(header[12:], uint16(len(tags)))
+	binary.BigEndian.PutUint32(header[16:], uint32(f.sfntSize()))
+	binary.BigEndian.PutUint32(header[20:], uint32(compressed.Len()))
+	binary.BigEndian.PutUint16(header[24:], major)
+	binary.BigEndian.PutUint16(header[26:], minor)
+
+	out := make([]byte, 0, length)
+	out = append(out, header...)
+	out = append(out, dir...)
+	out = append(out, compressed.Bytes()...)
+	return append(out, make([]byte, length-len(out))...), nil
+}
+
+// woffVersion returns the font revision of head as the major and minor version of a WOFF header
+func (f *Font) woffVersion() (uint16, uint16, error) {
+	head, err := f.table("head", 8)
+	if err != nil {
+		return 0, 0, err
+	}
+	return u16(head, 4), u16(head, 6), nil
+}
+
+// sfntSize returns the size of the font file with its tables padded to 4 bytes
+func (f *Font) sfntSize() int {
+	size := 12 + 16*len(f.Tables)
+	for _, tr := range f.Tables {
+		size += pad4(int(tr.Length))
+	}
+	return size
+}
+
+// zlibCompress compresses data at the best compression level. Writing to a buffer does not fail.
+func zlibCompress(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// base128 encodes v as a UIntBase128
+func base128(v uint32) []byte {
+	b := []byte{byte(v & 0x7F)}
+	for v >>= 7; v > 0; v >>= 7 {
+		b = append([]byte{byte(v&0x7F) | 0x80}, b...)
+	}
+	return b
+}
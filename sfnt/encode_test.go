@@ -0,0 +1,77 @@
+package sfnt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeWOFF(t *testing.T) {
+	font := testFont()
+	data, err := EncodeWOFF(font)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := ParseWOFF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Format != "woff" || w.Flavor != TrueType || w.TotalSfntSize != uint32(len(font)) || w.MajorVersion != 2 ||
+		w.MinorVersion != 0x41 || len(w.Tables) != 8 {
+		t.Errorf("bad header %+v", w)
+	}
+
+	decoded, err := Decode(data, &DecodeOptions{VerifyChecksums: true, VerifyBlocks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, font) {
+		t.Errorf("decoded font differs from the original")
+	}
+
+	if _, err := EncodeWOFF(data); err == nil {
+		t.Errorf("expect error encoding a woff file")
+	}
+}
+
+func TestEncodeWOFF2(t *testing.T) {
+	font, err := Decode(woff2File(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := EncodeWOFF2(font)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := ParseWOFF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Format != "woff2" || w.TotalSfntSize != uint32(len(font)) || len(w.Tables) != 7 || len(data)%4 != 0 {
+		t.Errorf("bad header %+v", w)
+	}
+	if w.Tables[1].Tag != "glyf" || w.Tables[2].Tag != "loca" || w.Tables[1].Transformed {
+		t.Errorf("bad table directory %+v", w.Tables)
+	}
+
+	decoded, err := Decode(data, &DecodeOptions{VerifyChecksums: true, VerifyBlocks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := Parse(font)
+	got, err := Parse(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range want.Tables {
+		if tr.Tag == "head" {
+			continue
+		}
+		if !bytes.Equal(mustTable(t, got, tr.Tag), mustTable(t, want, tr.Tag)) {
+			t.Errorf("%s differs from the original", tr.Tag)
+		}
+	}
+	head, err := got.Head()
+	if err != nil || head.Flags&headFlagLossless == 0 {
+		t.Errorf("got head %+v, %v; want flag bit 11", head, err)
+	}
+}
@@ -0,0 +1,162 @@
+package sfnt
+
+import "encoding/binary"
+
+// GSUB lookup types that produce glyphs
+const (
+	gsubSingle       = 1
+	gsubMultiple     = 2
+	gsubAlternate    = 3
+	gsubLigature     = 4
+	gsubExtension    = 7
+	gsubReverseChain = 8
+)
+
+// view is a part of a table that reads zeros past its end, for layout tables, which are made of offsets to
+// subtables
+type view []byte
+
+func (v view) u16(off int) uint16 {
+	if off < 0 || off+2 > len(v) {
+		return 0
+	}
+	return binary.BigEndian.Uint16(v[off:])
+}
+
+func (v view) u32(off int) uint32 {
+	if off < 0 || off+4 > len(v) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(v[off:])
+}
+
+// at returns the subtable at off, or nil if it is out of bounds
+func (v view) at(off int) view {
+	if off <= 0 || off >= len(v) {
+		return nil
+	}
+	return v[off:]
+}
+
+// count returns the number of items of size bytes in the array at off, no more than fit in v
+func (v view) count(off, n, size int) int {
+	if max := (len(v) - off) / size; n > max {
+		if max < 0 {
+			return 0
+		}
+		return max
+	}
+	return n
+}
+
+// gsubClosure returns the glyphs that the lookups of a GSUB table substitute for the glyphs of keep. All lookups are
+// applied, whatever the features, scripts and contexts that use them.
+func gsubClosure(gsub []byte, keep map[GlyphID]bool) map[GlyphID]bool {
+	result := map[GlyphID]bool{}
+	v := view(gsub)
+	lookupList := v.at(int(v.u16(8)))
+	n := lookupList.count(2, int(lookupList.u16(0)), 2)
+	for i := 0; i < n; i++ {
+		lookup := lookupList.at(int(lookupList.u16(2 + 2*i)))
+		lookupType := int(lookup.u16(0))
+		m := lookup.count(6, int(lookup.u16(4)), 2)
+		for j := 0; j < m; j++ {
+			gsubSubtable(lookup.at(int(lookup.u16(6+2*j))), lookupType, keep, result)
+		}
+	}
+	return result
+}
+
+// gsubSubtable adds the glyphs a lookup subtable substitutes for the glyphs of keep to result
+func gsubSubtable(st view, lookupType int, keep, result map[GlyphID]bool) {
+	if st == nil {
+		return
+	}
+	if lookupType == gsubExtension {
+		if lookupType = int(st.u16(2)); lookupType != gsubExtension {
+			gsubSubtable(st.at(int(st.u32(4))), lookupType, keep, result)
+		}
+		return
+	}
+
+	covered := coverage(st.at(int(st.u16(2))))
+	switch lookupType {
+	case gsubSingle:
+		if st.u16(0) == 1 {
+			delta := st.u16(4)
+			for _, g := range covered {
+				if keep[g] {
+					result[GlyphID(uint16(g)+delta)] = true
+				}
+			}
+			return
+		}
+		n := st.count(6, int(st.u16(4)), 2)
+		for i, g := range covered {
+			if i < n && keep[g] {
+				result[GlyphID(st.u16(6+2*i))] = true
+			}
+		}
+	case gsubMultiple, gsubAlternate:
+		n := st.count(6, int(st.u16(4)), 2)
+		for i, g := range covered {
+			if i >= n || !keep[g] {
+				continue
+			}
+			seq := st.at(int(st.u16(6 + 2*i)))
+			m := seq.count(2, int(seq.u16(0)), 2)
+			for j := 0; j < m; j++ {
+				result[GlyphID(seq.u16(2+2*j))] = true
+			}
+		}
+	case gsubLigature:
+		n := st.count(6, int(st.u16(4)), 2)
+		for i, g := range covered {
+			if i >= n || !keep[g] {
+				continue
+			}
+			set := st.at(int(st.u16(6 + 2*i)))
+			m := set.count(2, int(set.u16(0)), 2)
+			for j := 0; j < m; j++ {
+				lig := set.at(int(set.u16(2 + 2*j)))
+				components := lig.count(4, int(lig.u16(2))-1, 2)
+				all := true
+				for k := 0; k < components && all; k++ {
+					all = keep[GlyphID(lig.u16(4+2*k))]
+				}
+				if lig != nil && all {
+					result[GlyphID(lig.u16(0))] = true
+				}
+			}
+		}
+	case gsubReverseChain:
+		off := 4 + 2*int(st.u16(4))
+		off += 2 + 2*int(st.u16(off))
+		n := st.count(off+2, int(st.u16(off)), 2)
+		for i, g := range covered {
+			if i < n && keep[g] {
+				result[GlyphID(st.u16(off+2+2*i))] = true
+			}
+		}
+	}
+}
+
+// coverage returns the glyphs of a coverage table, in coverage index order
+func coverage(v view) []GlyphID {
+	result := []GlyphID{}
+	switch v.u16(0) {
+	case 1:
+		n := v.count(4, int(v.u16(2)), 2)
+		for i := 0; i < n; i++ {
+			result = append(result, GlyphID(v.u16(4+2*i)))
+		}
+	case 2:
+		n := v.count(4, int(v.u16(2)), 6)
+		for i := 0; i < n; i++ {
+			for g := int(v.u16(4 + 6*i)); g <= int(v.u16(6+6*i)) && len(result) <= 0xFFFF; g++ {
+				result = append(result, GlyphID(g))
+			}
+		}
+	}
+	return result
+}
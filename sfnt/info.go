@@ -0,0 +1,163 @@
+package sfnt
+
+import (
+	"errors"
+)
+
+// Info summarizes a font: its names, style, metrics, variation axes and characters
+type Info struct {
+	// Outlines is truetype for glyf outlines and cff for CFF outlines
+	Outlines string   `json:"outlines"`
+	Tables   []string `json:"tables"`
+	Family   string   `json:"family"`
+	// Subfamily is the style name, such as Bold Italic
+	Subfamily         string `json:"subfamily"`
+	TypographicFamily string `json:"typographicFamily,omitempty"`
+	// TypographicSubfamily is the style name in fonts with more than the four basic styles, such as Light
+	TypographicSubfamily string `json:"typographicSubfamily,omitempty"`
+	FullName             string `json:"fullName"`
+	PostScriptName       string `json:"postScriptName"`
+	// Version is the version string, such as Version 2.001
+	Version      string  `json:"version"`
+	FontRevision float64 `json:"fontRevision"`
+	WeightClass  uint16  `json:"weightClass,omitempty"`
+	WidthClass   uint16  `json:"widthClass,omitempty"`
+	Italic       bool    `json:"italic"`
+	ItalicAngle  float64 `json:"italicAngle"`
+	IsFixedPitch bool    `json:"isFixedPitch"`
+	UnitsPerEm   uint16  `json:"unitsPerEm"`
+	NumGlyphs    int     `json:"numGlyphs"`
+	Metrics      Metrics `json:"metrics"`
+	// Axes and Instances are empty for a static font
+	Axes      []NamedAxis     `json:"axes"`
+	Instances []NamedInstance `json:"instances"`
+	// Runes are the characters mapped to a glyph, in order
+	Runes []rune `json:"-"`
+}
+
+// Metrics are the vertical metrics of a font, in font units
+type Metrics struct {
+	Ascender      int16  `json:"ascender"`
+	Descender     int16  `json:"descender"`
+	LineGap       int16  `json:"lineGap"`
+	TypoAscender  int16  `json:"typoAscender"`
+	TypoDescender int16  `json:"typoDescender"`
+	TypoLineGap   int16  `json:"typoLineGap"`
+	WinAscent     uint16 `json:"winAscent"`
+	WinDescent    uint16 `json:"winDescent"`
+	// UseTypoMetrics is true if the typo metrics should be used for line spacing
+	UseTypoMetrics bool  `json:"useTypoMetrics"`
+	XHeight        int16 `json:"xHeight,omitempty"`
+	CapHeight      int16 `json:"capHeight,omitempty"`
+}
+
+// NamedAxis is a variation axis with its name, such as Weight
+type NamedAxis struct {
+	Axis
+	Name string `json:"name"`
+}
+
+// NamedInstance is a named instance with its name and coordinates by axis tag
+type NamedInstance struct {
+	Name           string             `json:"name"`
+	PostScriptName string             `json:"postScriptName,omitempty"`
+	Coordinates    map[string]float64 `json:"coordinates"`
+}
+
+// Info decodes the head, hhea, maxp, name and cmap tables, and the OS/2, post and fvar tables if present
+func (f *Font) Info() (*Info, error) {
+	info := &Info{Outlines: "truetype", Tables: []string{}, Axes: []NamedAxis{}, Instances: []NamedInstance{}}
+	if f.Version == OpenType {
+		info.Outlines = "cff"
+	}
+	for _, tr := range f.Tables {
+		info.Tables = append(info.Tables, tr.Tag)
+	}
+
+	head, err := f.Head()
+	if err != nil {
+		return nil, err
+	}
+	info.FontRevision = head.FontRevision
+	info.UnitsPerEm = head.UnitsPerEm
+	info.Italic = head.MacStyle&2 != 0
+
+	hhea, err := f.Hhea()
+	if err != nil {
+		return nil, err
+	}
+	info.Metrics.Ascender = hhea.Ascender
+	info.Metrics.Descender = hhea.Descender
+	info.Metrics.LineGap = hhea.LineGap
+
+	if info.NumGlyphs, err = f.NumGlyphs(); err != nil {
+		return nil, err
+	}
+
+	names, err := f.Names()
+	if err != nil {
+		return nil, err
+	}
+	info.Family = names.Get(NameFamily)
+	info.Subfamily = names.Get(NameSubfamily)
+	info.TypographicFamily = names.Get(NameTypographicFamily)
+	info.TypographicSubfamily = names.Get(NameTypographicSubfamily)
+	info.FullName = names.Get(NameFull)
+	info.PostScriptName = names.Get(NamePostScript)
+	info.Version = names.Get(NameVersion)
+
+	cmap, err := f.Cmap()
+	if err != nil {
+		return nil, err
+	}
+	info.Runes = cmap.Runes()
+
+	os2, err := f.OS2()
+	switch {
+	case err == nil:
+		info.WeightClass = os2.WeightClass
+		info.WidthClass = os2.WidthClass
+		info.Italic = info.Italic || os2.FsSelection&1 != 0
+		info.Metrics.TypoAscender = os2.TypoAscender
+		info.Metrics.TypoDescender = os2.TypoDescender
+		info.Metrics.TypoLineGap = os2.TypoLineGap
+		info.Metrics.WinAscent = os2.WinAscent
+		info.Metrics.WinDescent = os2.WinDescent
+		info.Metrics.UseTypoMetrics = os2.FsSelection&(1<<7) != 0
+		info.Metrics.XHeight = os2.XHeight
+		info.Metrics.CapHeight = os2.CapHeight
+	case !errors.Is(err, ErrNotFound):
+		return nil, err
+	}
+
+	post, err := f.Post()
+	switch {
+	case err == nil:
+		info.ItalicAngle = post.ItalicAngle
+		info.IsFixedPitch = post.IsFixedPitch
+	case !errors.Is(err, ErrNotFound):
+		return nil, err
+	}
+
+	fvar, err := f.Fvar()
+	switch {
+	case err == nil:
+		for _, a := range fvar.Axes {
+			info.Axes = append(info.Axes, NamedAxis{Axis: a, Name: names.Get(a.NameID)})
+		}
+		for _, inst := range fvar.Instances {
+			ni := NamedInstance{Name: names.Get(inst.SubfamilyNameID), Coordinates: map[string]float64{}}
+			if inst.PostScriptNameID != 0 && inst.PostScriptNameID != 0xFFFF {
+				ni.PostScriptName = names.Get(inst.PostScriptNameID)
+			}
+			for i, a := range fvar.Axes {
+				ni.Coordinates[a.Tag] = inst.Coordinates[i]
+			}
+			info.Instances = append(info.Instances, ni)
+		}
+	case !errors.Is(err, ErrNotFound):
+		return nil, err
+	}
+
+	return info, nil
+}
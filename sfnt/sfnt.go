@@ -0,0 +1,138 @@
+// Package sfnt reads TrueType and OpenType font files, such as the TTF files served by Google Fonts.
+package sfnt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	// ErrNotFound is returned when the font has no table with the requested tag
+	ErrNotFound = errors.New("table not found")
+	// ErrFormat is returned when the data is not a valid font file
+	ErrFormat = errors.New("invalid font data")
+)
+
+const (
+	// TrueType is the sfnt version of fonts with glyf outlines
+	TrueType uint32 = 0x00010000
+	// OpenType is the sfnt version of fonts with CFF outlines, OTTO
+	OpenType uint32 = 0x4F54544F
+	// AppleTrueType is the sfnt version of old Apple TrueType fonts, true
+	AppleTrueType uint32 = 0x74727565
+)
+
+// TableRecord is an entry of the table directory
+type TableRecord struct {
+	Tag      string `json:"tag"`
+	Checksum uint32 `json:"checksum"`
+	Offset   uint32 `json:"offset"`
+	Length   uint32 `json:"length"`
+}
+
+// Font is a parsed font file. Tables are decoded on demand.
+type Font struct {
+	// Version is the sfnt version: TrueType, OpenType or AppleTrueType
+	Version uint32
+	// Tables is the table directory, sorted by tag
+	Tables []TableRecord
+	data   []byte
+}
+
+// Parse reads the table directory of a TrueType or OpenType font. Font collections are not supported.
+func Parse(data []byte) (*Font, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("%w: file too short", ErrFormat)
+	}
+
+	f := &Font{Version: u32(data, 0), data: data}
+	switch f.Version {
+	case TrueType, OpenType, AppleTrueType:
+	case ttcSignature:
+		return nil, fmt.Errorf("%w: font collections are not supported", ErrFormat)
+	default:
+		return nil, fmt.Errorf("%w: unknown sfnt version %08x", ErrFormat, f.Version)
+	}
+
+	numTables := int(u16(data, 4))
+	if len(data) < 12+16*numTables {
+		return nil, fmt.Errorf("%w: table directory truncated", ErrFormat)
+	}
+	for i := 0; i < numTables; i++ {
+		p := 12 + 16*i
+		tr := TableRecord{
+			Tag:      string(data[p : p+4]),
+			Checksum: u32(data, p+4),
+			Offset:   u32(data, p+8),
+			Length:   u32(data, p+12),
+		}
+		if uint64(tr.Offset)+uint64(tr.Length) > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: table %s out of bounds", ErrFormat, tr.Tag)
+		}
+		f.Tables = append(f.Tables, tr)
+	}
+	sort.Slice(f.Tables, func(i, j int) bool {
+		return f.Tables[i].Tag < f.Tables[j].Tag
+	})
+	return f, nil
+}
+
+// HasTable reports whether the font has a table with the tag
+func (f *Font) HasTable(tag string) bool {
+	_, err := f.Table(tag)
+	return err == nil
+}
+
+// Table returns the raw data of the table with the tag, such as cmap or OS/2
+func (f *Font) Table(tag string) ([]byte, error) {
+	i := sort.Search(len(f.Tables), func(i int) bool {
+		return f.Tables[i].Tag >= tag
+	})
+	if i == len(f.Tables) || f.Tables[i].Tag != tag {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, tag)
+	}
+	tr := f.Tables[i]
+	return f.data[tr.Offset : tr.Offset+tr.Length], nil
+}
+
+// Data returns the whole font file
+func (f *Font) Data() []byte {
+	return f.data
+}
+
+// table returns the table with the tag, or an error if it is shorter than minLen
+func (f *Font) table(tag string, minLen int) ([]byte, error) {
+	b, err := f.Table(tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < minLen {
+		return nil, fmt.Errorf("%w: table %s too short", ErrFormat, tag)
+	}
+	return b, nil
+}
+
+func u16(b []byte, off int) uint16 {
+	return binary.BigEndian.Uint16(b[off:])
+}
+
+func i16(b []byte, off int) int16 {
+	return int16(binary.BigEndian.Uint16(b[off:]))
+}
+
+func u32(b []byte, off int) uint32 {
+	return binary.BigEndian.Uint32(b[off:])
+}
+
+// fixed reads a 16.16 fixed point number
+func fixed(b []byte, off int) float64 {
+	return float64(int32(u32(b, off))) / 65536
+}
+
+// round3 rounds v to 3 decimals, so that fixed point values such as 1.0009765625 print as 1.001
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
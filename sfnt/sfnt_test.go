@@ -0,0 +1,246 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"unicode/utf16"
+)
+
+// be writes values in big endian order
+func be(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			buf.WriteString(s)
+			continue
+		}
+		if err := binary.Write(&buf, binary.BigEndian, v); err != nil {
+			panic(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func nameTable(records map[uint16]string) []byte {
+	ids := []int{}
+	for id := range records {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	header := be(uint16(0), uint16(len(ids)+1), uint16(6+12*(len(ids)+1)))
+	storage := []byte{}
+	for _, id := range ids {
+		s := be(utf16.Encode([]rune(records[uint16(id)])))
+		header = append(header, be(uint16(3), uint16(1), uint16(0x409), uint16(id), uint16(len(s)), uint16(len(storage)))...)
+		storage = append(storage, s...)
+	}
+	// a Mac Roman record of the family, which Windows strings take precedence over
+	header = append(header, be(uint16(1), uint16(0), uint16(0), NameFamily, uint16(4), uint16(len(storage)))...)
+	storage = append(storage, "M\x8aac"...)
+	return append(header, storage...)
+}
+
+func testFont() []byte {
+	head := be(uint16(1), uint16(0), uint32(0x00020041), uint32(0), uint32(headMagic), uint16(3), uint16(1000),
+		int64(3600), int64(7200), int16(-100), int16(-250), int16(1100), int16(950), uint16(2), uint16(8),
+		int16(2), int16(0), int16(0))
+	hhea := be(uint16(1), uint16(0), int16(950), int16(-250), int16(0), uint16(1100), int16(-100), int16(-50),
+		int16(1000), int16(1), int16(0), int16(0), make([]byte, 8), int16(0), uint16(4))
+	maxp := be(uint32(0x00005000), uint16(4))
+	os2 := be(uint16(4), int16(500), uint16(300), uint16(5), uint16(0), make([]byte, 22), make([]byte, 10),
+		uint32(1), uint32(0), uint32(0), uint32(0), "GOOG", uint16(1|1<<7), uint16(0x20), uint16(0xFFFF),
+		int16(900), int16(-200), int16(100), uint16(1000), uint16(300), uint32(1), uint32(0),
+		int16(500), int16(700), uint16(0), uint16(0x20), uint16(2))
+	post := be(uint32(0x00030000), int32(-12<<16), int16(-100), int16(50), uint32(0), make([]byte, 16))
+
+	// format 4: A-C to glyphs 1-3 by delta, U+00E9 through the glyph array, then the 0xFFFF segment
+	cmap4 := be(uint16(4), uint16(0), uint16(0), uint16(6), uint16(0), uint16(0), uint16(0),
+		uint16(0x43), uint16(0xE9), uint16(0xFFFF), uint16(0),
+		uint16(0x41), uint16(0xE9), uint16(0xFFFF),
+		int16(1-0x41), int16(0), int16(1),
+		uint16(0), uint16(4), uint16(0),
+		uint16(3))
+	// format 12: the same plus U+1F600
+	cmap12 := be(uint16(12), uint16(0), uint32(0), uint32(0), uint32(3),
+		uint32(0x41), uint32(0x43), uint32(1),
+		uint32(0xE9), uint32(0xE9), uint32(3),
+		uint32(0x1F600), uint32(0x1F600), uint32(2))
+	cmap := be(uint16(0), uint16(2), uint16(3), uint16(1), uint32(20), uint16(3), uint16(10), uint32(20+len(cmap4)))
+	cmap = append(append(cmap, cmap4...), cmap12...)
+
+	fvar := be(uint16(1), uint16(0), uint16(16), uint16(2), uint16(1), uint16(20), uint16(2), uint16(10),
+		"wght", int32(100<<16), int32(400<<16), int32(900<<16), uint16(0), uint16(256),
+		uint16(257), uint16(0), int32(300<<16), uint16(258),
+		uint16(2), uint16(0), int32(400<<16), uint16(0xFFFF))
+
+	name := nameTable(map[uint16]string{
+		NameFamily:     "Test Sans",
+		NameSubfamily:  "Italic",
+		NameFull:       "Test Sans Light Italic",
+		NameVersion:    "Version 2.001",
+		NamePostScript: "TestSans-LightItalic",
+		256:            "Weight",
+		257:            "Light",
+		258:            "TestSans-Light",
+	})
+
+	return Build(TrueType, map[string][]byte{
+		"head": head, "hhea": hhea, "maxp": maxp, "OS/2": os2, "post": post, "cmap": cmap, "fvar": fvar, "name": name,
+	})
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse(testFont())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Tables) != 8 || f.Tables[0].Tag != "OS/2" || !f.HasTable("fvar") || f.HasTable("glyf") {
+		t.Errorf("bad table directory %+v", f.Tables)
+	}
+	if _, err := f.Table("GSUB"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+
+	for _, data := range [][]byte{nil, []byte("wOF2\x00\x01\x00\x00\x00\x00\x00\x00"), be(TrueType, uint16(1), make([]byte, 6))} {
+		if _, err := Parse(data); !errors.Is(err, ErrFormat) {
+			t.Errorf("%q: got %v, want ErrFormat", data, err)
+		}
+	}
+}
+
+func TestInfo(t *testing.T) {
+	f, err := Parse(testFont())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := f.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Family != "Test Sans" || info.Subfamily != "Italic" || info.PostScriptName != "TestSans-LightItalic" ||
+		info.Version != "Version 2.001" || info.FontRevision != 2.001 {
+		t.Errorf("bad names %+v", info)
+	}
+	if info.WeightClass != 300 || info.WidthClass != 5 || !info.Italic || info.ItalicAngle != -12 ||
+		info.UnitsPerEm != 1000 || info.NumGlyphs != 4 {
+		t.Errorf("bad style %+v", info)
+	}
+	wantMetrics := Metrics{Ascender: 950, Descender: -250, TypoAscender: 900, TypoDescender: -200, TypoLineGap: 100,
+		WinAscent: 1000, WinDescent: 300, UseTypoMetrics: true, XHeight: 500, CapHeight: 700}
+	if info.Metrics != wantMetrics {
+		t.Errorf("got metrics %+v, want %+v", info.Metrics, wantMetrics)
+	}
+	if want := []rune{'A', 'B', 'C', 'é', 0x1F600}; !reflect.DeepEqual(info.Runes, want) {
+		t.Errorf("got runes %U, want %U", info.Runes, want)
+	}
+
+	wantAxes := []NamedAxis{{Axis: Axis{Tag: "wght", Min: 100, Default: 400, Max: 900, NameID: 256}, Name: "Weight"}}
+	if !reflect.DeepEqual(info.Axes, wantAxes) {
+		t.Errorf("got axes %+v, want %+v", info.Axes, wantAxes)
+	}
+	wantInstances := []NamedInstance{
+		{Name: "Light", PostScriptName: "TestSans-Light", Coordinates: map[string]float64{"wght": 300}},
+		{Name: "Italic", Coordinates: map[string]float64{"wght": 400}},
+	}
+	if !reflect.DeepEqual(info.Instances, wantInstances) {
+		t.Errorf("got instances %+v, want %+v", info.Instances, wantInstances)
+	}
+}
+
+func TestCmapFormat4(t *testing.T) {
+	data := testFont()
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// drop the format 12 subtable by renaming its encoding to an unknown one
+	cmap, _ := f.Table("cmap")
+	binary.BigEndian.PutUint16(cmap[12:], 99)
+	c, err := f.Cmap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Format != 4 || c.Len() != 4 {
+		t.Errorf("got format %d with %d runes, want format 4 with 4 runes", c.Format, c.Len())
+	}
+	for r, want := range map[rune]GlyphID{'A': 1, 'C': 3, 'é': 3} {
+		if g, ok := c.Lookup(r); !ok || g != want {
+			t.Errorf("%c: got glyph %d, want %d", r, g, want)
+		}
+	}
+	if _, ok := c.Lookup('D'); ok {
+		t.Errorf("D should not be mapped")
+	}
+}
+
+func TestNames(t *testing.T) {
+	names := Names{
+		{PlatformID: 1, NameID: NameFamily, Value: "Mac"},
+		{PlatformID: 3, EncodingID: 1, LanguageID: 0x407, NameID: NameFamily, Value: "German"},
+		{PlatformID: 3, EncodingID: 1, LanguageID: 0x409, NameID: NameFamily, Value: "English"},
+	}
+	if got := names.Get(NameFamily); got != "English" {
+		t.Errorf("got %q, want English", got)
+	}
+	if got := names[:1].Get(NameFamily); got != "Mac" {
+		t.Errorf("got %q, want Mac", got)
+	}
+	if got := decodeMacRoman([]byte("M\x8aac\xff")); got != "Mäacˇ" {
+		t.Errorf("got %q", got)
+	}
+	if len(macRomanHigh) != 128 {
+		t.Errorf("Mac Roman table has %d runes, want 128", len(macRomanHigh))
+	}
+}
+
+// cmap12Font returns a font whose cmap has a single format 12 subtable with the given groups
+func cmap12Font(groups [][3]uint32) []byte {
+	cmap := make([]byte, 12+16+12*len(groups))
+	binary.BigEndian.PutUint16(cmap[2:], 1)
+	binary.BigEndian.PutUint16(cmap[4:], 3)
+	binary.BigEndian.PutUint16(cmap[6:], 10)
+	binary.BigEndian.PutUint32(cmap[8:], 12)
+	binary.BigEndian.PutUint16(cmap[12:], 12)
+	binary.BigEndian.PutUint32(cmap[16:], uint32(16+12*len(groups)))
+	binary.BigEndian.PutUint32(cmap[24:], uint32(len(groups)))
+	for i, g := range groups {
+		for j, v := range g {
+			binary.BigEndian.PutUint32(cmap[28+12*i+4*j:], v)
+		}
+	}
+	return Build(TrueType, map[string][]byte{"cmap": cmap})
+}
+
+func TestCmapFormat12Limit(t *testing.T) {
+	f, err := Parse(cmap12Font([][3]uint32{{0, 0x10FFFF, 1}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := f.Cmap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 0xFFFF {
+		t.Errorf("got %d runes, want %d", c.Len(), 0xFFFF)
+	}
+
+	// the same full range again and again
+	groups := make([][3]uint32, 1000)
+	for i := range groups {
+		groups[i] = [3]uint32{0, 0x10FFFF, 1}
+	}
+	f, err = Parse(cmap12Font(groups))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Cmap(); !errors.Is(err, ErrFormat) {
+		t.Errorf("got %v, want ErrFormat", err)
+	}
+}
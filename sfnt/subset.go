@@ -0,0 +1,532 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// SubsetOptions controls how Subset reduces a font
+type SubsetOptions struct {
+	// DropLayout removes the OpenType layout tables, such as GSUB and GPOS, and the other tables that refer to glyphs
+	// by index, and renumbers the glyphs that are kept. Files are smaller, but ligatures, kerning and other features
+	// are lost. Without it, glyph indexes do not change: the glyphs not needed are emptied, and the glyphs that GSUB
+	// substitutes for the glyphs kept are kept too.
+	DropLayout bool
+}
+
+// glyphIndependentTables are the tables kept with DropLayout, as they do not refer to glyphs by index
+var glyphIndependentTables = map[string]bool{
+	"OS/2": true, "STAT": true, "MVAR": true, "VDMX": true, "avar": true, "cvar": true, "cvt ": true, "fpgm": true,
+	"fvar": true, "gasp": true, "head": true, "hhea": true, "maxp": true, "meta": true, "name": true, "post": true,
+	"prep": true, "vhea": true,
+}
+
+// Subset returns a font with only the glyphs needed to render the characters keep reports true for. keep is called
+// once for each character of the cmap. data can be a TTF, WOFF or WOFF2 file; the subset is a TTF file. The glyphs that composite glyphs are made of are kept, and the cmap, glyf, loca, hmtx and
+// gvar tables are rebuilt. Only fonts with TrueType outlines are supported.
+func Subset(data []byte, keep func(rune) bool, opts *SubsetOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &SubsetOptions{}
+	}
+	data, err := Decode(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if f.Version == OpenType || !f.HasTable("glyf") {
+		return nil, fmt.Errorf("%w: only fonts with TrueType outlines can be subset", ErrFormat)
+	}
+
+	s, err := newSubsetter(f)
+	if err != nil {
+		return nil, err
+	}
+	cmap, err := f.Cmap()
+	if err != nil {
+		return nil, err
+	}
+	mapping := map[rune]GlyphID{}
+	for _, r := range cmap.Runes() {
+		if g, _ := cmap.Lookup(r); int(g) < s.numGlyphs && keep(r) {
+			mapping[r] = g
+			s.keep[g] = true
+		}
+	}
+	if err := s.closure(!opts.DropLayout); err != nil {
+		return nil, err
+	}
+
+	// newGlyphs lists the old index of each glyph of the subset
+	newGlyphs := []GlyphID{}
+	for g := 0; g < s.numGlyphs; g++ {
+		if !opts.DropLayout || s.keep[GlyphID(g)] {
+			newGlyphs = append(newGlyphs, GlyphID(g))
+		}
+	}
+	remap := map[GlyphID]GlyphID{}
+	for i, g := range newGlyphs {
+		remap[g] = GlyphID(i)
+	}
+
+	tables := map[string][]byte{}
+	for _, tr := range f.Tables {
+		if opts.DropLayout && !glyphIndependentTables[tr.Tag] || tr.Tag == "DSIG" {
+			continue
+		}
+		tables[tr.Tag], _ = f.Table(tr.Tag)
+	}
+
+	glyf, loca, indexFormat, err := s.glyf(newGlyphs, remap)
+	if err != nil {
+		return nil, err
+	}
+	tables["glyf"], tables["loca"] = glyf, loca
+	head := append([]byte{}, tables["head"]...)
+	if len(head) < 54 {
+		return nil, fmt.Errorf("%w: table head too short", ErrFormat)
+	}
+	binary.BigEndian.PutUint16(head[50:], indexFormat)
+	tables["head"] = head
+
+	newMapping := map[rune]GlyphID{}
+	for r, g := range mapping {
+		newMapping[r] = remap[g]
+	}
+	tables["cmap"] = buildCmap(newMapping, cmap.PlatformID == 3 && cmap.EncodingID == 0)
+	if os2, ok := tables["OS/2"]; ok && len(os2) >= 68 && len(newMapping) > 0 {
+		tables["OS/2"] = withCharIndexes(os2, newMapping)
+	}
+
+	if gvar, err := f.Table("gvar"); err == nil {
+		if tables["gvar"], err = s.gvar(gvar, newGlyphs); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.DropLayout {
+		maxp := append([]byte{}, tables["maxp"]...)
+		binary.BigEndian.PutUint16(maxp[4:], uint16(len(newGlyphs)))
+		tables["maxp"] = maxp
+
+		for _, m := range [][2]string{{"hhea", "hmtx"}, {"vhea", "vmtx"}} {
+			if !f.HasTable(m[0]) {
+				continue
+			}
+			if !f.HasTable(m[1]) {
+				delete(tables, m[0])
+				continue
+			}
+			header, metrics, err := s.metrics(m[0], m[1], newGlyphs)
+			if err != nil {
+				return nil, err
+			}
+			tables[m[0]], tables[m[1]] = header, metrics
+		}
+
+		// glyph names are dropped, as version 3
+		if post, ok := tables["post"]; ok && len(post) >= 32 && u32(post, 0) != 0x00030000 {
+			post = append([]byte{}, post[:32]...)
+			binary.BigEndian.PutUint32(post, 0x00030000)
+			tables["post"] = post
+		}
+	}
+
+	return Build(f.Version, tables), nil
+}
+
+// subsetter holds the glyphs of a font being subset
+type subsetter struct {
+	font      *Font
+	numGlyphs int
+	glyphs    [][]byte
+	keep      map[GlyphID]bool
+}
+
+func newSubsetter(f *Font) (*subsetter, error) {
+	numGlyphs, err := f.NumGlyphs()
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := f.locaOffsets()
+	if err != nil {
+		return nil, err
+	}
+	glyf, err := f.Table("glyf")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &subsetter{font: f, numGlyphs: numGlyphs, glyphs: make([][]byte, numGlyphs), keep: map[GlyphID]bool{0: true}}
+	for i := range s.glyphs {
+		start, end := offsets[i], offsets[i+1]
+		if start > end || end > len(glyf) {
+			return nil, fmt.Errorf("%w: glyph %d out of bounds", ErrFormat, i)
+		}
+		s.glyphs[i] = glyf[start:end]
+	}
+	return s, nil
+}
+
+// closure adds the components of the composite glyphs kept, and the glyphs GSUB substitutes for them if withGSUB,
+// until no glyph is added
+func (s *subsetter) closure(withGSUB bool) error {
+	gsub, _ := s.font.Table("GSUB")
+	for {
+		n := len(s.keep)
+		if withGSUB && gsub != nil {
+			for g := range gsubClosure(gsub, s.keep) {
+				if int(g) < s.numGlyphs {
+					s.keep[g] = true
+				}
+			}
+		}
+		for g := range s.keep {
+			offsets, err := componentOffsets(s.glyphs[g])
+			if err != nil {
+				return fmt.Errorf("glyph %d: %w", g, err)
+			}
+			for _, off := range offsets {
+				c := GlyphID(u16(s.glyphs[g], off))
+				if int(c) >= s.numGlyphs {
+					return fmt.Errorf("%w: glyph %d has unknown component %d", ErrFormat, g, c)
+				}
+				s.keep[c] = true
+			}
+		}
+		if len(s.keep) == n {
+			return nil
+		}
+	}
+}
+
+// glyf builds the glyf and loca tables of the subset, with the components of composite glyphs renumbered. Glyphs that
+// are not kept are empty.
+func (s *subsetter) glyf(newGlyphs []GlyphID, remap map[GlyphID]GlyphID) ([]byte, []byte, uint16, error) {
+	glyf := []byte{}
+	offsets := make([]int, 0, len(newGlyphs)+1)
+	for _, g := range newGlyphs {
+		offsets = append(offsets, len(glyf))
+		if !s.keep[g] {
+			continue
+		}
+		glyph := append([]byte{}, s.glyphs[g]...)
+		components, _ := componentOffsets(glyph)
+		for _, off := range components {
+			binary.BigEndian.PutUint16(glyph[off:], uint16(remap[GlyphID(u16(glyph, off))]))
+		}
+		glyf = append(glyf, glyph...)
+		glyf = append(glyf, make([]byte, pad4(len(glyf))-len(glyf))...)
+	}
+	offsets = append(offsets, len(glyf))
+
+	indexFormat := uint16(0)
+	if len(glyf)/2 > 0xFFFF {
+		indexFormat = 1
+	}
+	loca, err := buildLoca(offsets, indexFormat)
+	return glyf, loca, indexFormat, err
+}
+
+// metrics rebuilds hmtx or vmtx for the glyphs of the subset, and sets the number of long metrics of their header.
+// Trailing glyphs with the same advance share the last long metric.
+func (s *subsetter) metrics(headerTag, tag string, newGlyphs []GlyphID) ([]byte, []byte, error) {
+	header, err := s.font.table(headerTag, 36)
+	if err != nil {
+		return nil, nil, err
+	}
+	numLong := int(u16(header, 34))
+	b, err := s.font.Table(tag)
+	if err != nil {
+		return nil, nil, err
+	}
+	if numLong == 0 || numLong > s.numGlyphs || len(b) < 4*numLong+2*(s.numGlyphs-numLong) {
+		return nil, nil, fmt.Errorf("%w: table %s too short", ErrFormat, tag)
+	}
+
+	advances := make([]uint16, len(newGlyphs))
+	bearings := make([]uint16, len(newGlyphs))
+	for i, g := range newGlyphs {
+		if int(g) < numLong {
+			advances[i], bearings[i] = u16(b, 4*int(g)), u16(b, 4*int(g)+2)
+		} else {
+			advances[i], bearings[i] = u16(b, 4*(numLong-1)), u16(b, 4*numLong+2*(int(g)-numLong))
+		}
+	}
+	n := len(advances)
+	for n > 1 && advances[n-1] == advances[n-2] {
+		n--
+	}
+
+	metrics := []byte{}
+	for i := range advances {
+		if i < n {
+			metrics = append(metrics, be16s(advances[i])...)
+		}
+		metrics = append(metrics, be16s(bearings[i])...)
+	}
+	header = append([]byte{}, header...)
+	binary.BigEndian.PutUint16(header[34:], uint16(n))
+	return header, metrics, nil
+}
+
+// gvar rebuilds the gvar table with the variations of the glyphs of the subset
+func (s *subsetter) gvar(b []byte, newGlyphs []GlyphID) ([]byte, error) {
+	if len(b) < 20 {
+		return nil, fmt.Errorf("%w: table gvar too short", ErrFormat)
+	}
+	axisCount, sharedTupleCount := int(u16(b, 4)), int(u16(b, 6))
+	sharedTuplesOffset, glyphCount := int(u32(b, 8)), int(u16(b, 12))
+	longOffsets, dataOffset := u16(b, 14)&1 != 0, int(u32(b, 16))
+	if glyphCount != s.numGlyphs {
+		return nil, fmt.Errorf("%w: gvar has %d glyphs, maxp %d", ErrFormat, glyphCount, s.numGlyphs)
+	}
+
+	offsets := make([]int, glyphCount+1)
+	for i := range offsets {
+		if longOffsets {
+			if len(b) < 20+4*len(offsets) {
+				return nil, fmt.Errorf("%w: table gvar too short", ErrFormat)
+			}
+			offsets[i] = dataOffset + int(u32(b, 20+4*i))
+		} else {
+			if len(b) < 20+2*len(offsets) {
+				return nil, fmt.Errorf("%w: table gvar too short", ErrFormat)
+			}
+			offsets[i] = dataOffset + 2*int(u16(b, 20+2*i))
+		}
+	}
+	sharedTuples := 2 * axisCount * sharedTupleCount
+	if sharedTuplesOffset+sharedTuples > len(b) {
+		return nil, fmt.Errorf("%w: gvar shared tuples out of bounds", ErrFormat)
+	}
+
+	// long offsets, then the shared tuples, then the variations of each glyph
+	headerLen := 20 + 4*(len(newGlyphs)+1)
+	out := make([]byte, headerLen, headerLen+sharedTuples)
+	copy(out, b[:20])
+	binary.BigEndian.PutUint16(out[12:], uint16(len(newGlyphs)))
+	binary.BigEndian.PutUint16(out[14:], u16(b, 14)|1)
+	binary.BigEndian.PutUint32(out[8:], uint32(headerLen))
+	out = append(out, b[sharedTuplesOffset:sharedTuplesOffset+sharedTuples]...)
+	binary.BigEndian.PutUint32(out[16:], uint32(len(out)))
+
+	data := []byte{}
+	for i, g := range newGlyphs {
+		binary.BigEndian.PutUint32(out[20+4*i:], uint32(len(data)))
+		if !s.keep[g] {
+			continue
+		}
+		start, end := offsets[g], offsets[g+1]
+		if start > end || end > len(b) {
+			return nil, fmt.Errorf("%w: gvar glyph %d out of bounds", ErrFormat, g)
+		}
+		data = append(data, b[start:end]...)
+	}
+	binary.BigEndian.PutUint32(out[20+4*len(newGlyphs):], uint32(len(data)))
+	return append(out, data...), nil
+}
+
+// componentOffsets returns the offsets of the glyph indexes of the components of a composite glyph, or nil for other
+// glyphs
+func componentOffsets(glyph []byte) ([]int, error) {
+	if len(glyph) < 10 || i16(glyph, 0) >= 0 {
+		return nil, nil
+	}
+	r := &reader{b: glyph, off: 10}
+	result := []int{}
+	for {
+		flags := r.u16()
+		result = append(result, r.off)
+		r.u16()
+		size := 2
+		if flags&flagArgsAreWords != 0 {
+			size = 4
+		}
+		switch {
+		case flags&flagHaveScale != 0:
+			size += 2
+		case flags&flagHaveXYScale != 0:
+			size += 4
+		case flags&flagHaveTwoByTwo != 0:
+			size += 8
+		}
+		r.bytes(size)
+		if r.err != nil {
+			return nil, fmt.Errorf("%w: composite glyph truncated", ErrFormat)
+		}
+		if flags&flagMoreComponents == 0 {
+			return result, nil
+		}
+	}
+}
+
+// buildCmap writes a cmap table with a format 4 subtable for the Basic Multilingual Plane, and a format 12 subtable if
+// some characters are outside it. Symbol fonts keep the Windows symbol encoding.
+func buildCmap(glyphs map[rune]GlyphID, symbol bool) []byte {
+	runes := make([]rune, 0, len(glyphs))
+	for r := range glyphs {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+
+	type subtable struct {
+		encodingID uint16
+		data       []byte
+	}
+	subtables := []subtable{}
+	if symbol {
+		subtables = append(subtables, subtable{0, cmapFormat4(runes, glyphs)})
+	} else {
+		subtables = append(subtables, subtable{1, cmapFormat4(runes, glyphs)})
+		if len(runes) > 0 && runes[len(runes)-1] > 0xFFFF {
+			subtables = append(subtables, subtable{10, cmapFormat12(runes, glyphs)})
+		}
+	}
+
+	cmap := be16s(0, uint16(len(subtables)))
+	offset := 4 + 8*len(subtables)
+	for _, st := range subtables {
+		cmap = append(cmap, be16s(3, st.encodingID)...)
+		cmap = append(cmap, be32(uint32(offset))...)
+		offset += len(st.data)
+	}
+	for _, st := range subtables {
+		cmap = append(cmap, st.data...)
+	}
+	return cmap
+}
+
+// cmapFormat4 writes a format 4 subtable of the characters in the Basic Multilingual Plane. Each run of consecutive
+// characters is a segment, which maps to consecutive glyphs with a delta or to a glyph array, whichever is smaller.
+// Characters that do not fit in the 64K the format allows are left out.
+func cmapFormat4(runes []rune, glyphs map[rune]GlyphID) []byte {
+	type segment struct {
+		start, end rune
+		delta      uint16
+		array      []GlyphID
+	}
+	segments := []segment{}
+	size := 16 + 8
+	for i := 0; i < len(runes) && runes[i] < 0xFFFF; {
+		j := i + 1
+		for j < len(runes) && runes[j] < 0xFFFF && runes[j] == runes[j-1]+1 {
+			j++
+		}
+		run := runes[i:j]
+		i = j
+
+		deltas := []segment{}
+		for k := 0; k < len(run); {
+			l := k + 1
+			for l < len(run) && glyphs[run[l]] == glyphs[run[l-1]]+1 {
+				l++
+			}
+			deltas = append(deltas, segment{start: run[k], end: run[l-1], delta: uint16(glyphs[run[k]]) - uint16(run[k])})
+			k = l
+		}
+		if 8*len(deltas) <= 8+2*len(run) {
+			if size+8*len(deltas) > 0xFFFF {
+				break
+			}
+			segments = append(segments, deltas...)
+			size += 8 * len(deltas)
+		} else {
+			if size+8+2*len(run) > 0xFFFF {
+				break
+			}
+			array := make([]GlyphID, len(run))
+			for k, r := range run {
+				array[k] = glyphs[r]
+			}
+			segments = append(segments, segment{start: run[0], end: run[len(run)-1], array: array})
+			size += 8 + 2*len(run)
+		}
+	}
+	segments = append(segments, segment{start: 0xFFFF, end: 0xFFFF, delta: 1})
+
+	segCount := len(segments)
+	searchRange := 2
+	entrySelector := 0
+	for searchRange*2 <= 2*segCount {
+		searchRange *= 2
+		entrySelector++
+	}
+	ends, starts, deltas, rangeOffsets, arrays := []uint16{}, []uint16{}, []uint16{}, []uint16{}, []uint16{}
+	for i, seg := range segments {
+		ends = append(ends, uint16(seg.end))
+		starts = append(starts, uint16(seg.start))
+		deltas = append(deltas, seg.delta)
+		if seg.array == nil {
+			rangeOffsets = append(rangeOffsets, 0)
+			continue
+		}
+		// from the idRangeOffset of the segment to its glyphs in the array that follows
+		rangeOffsets = append(rangeOffsets, uint16(2*(segCount-i+len(arrays))))
+		for _, g := range seg.array {
+			arrays = append(arrays, uint16(g))
+		}
+	}
+
+	b := be16s(4, uint16(size), 0, uint16(2*segCount), uint16(searchRange), uint16(entrySelector),
+		uint16(2*segCount-searchRange))
+	b = append(b, be16s(ends...)...)
+	b = append(b, 0, 0)
+	b = append(b, be16s(starts...)...)
+	b = append(b, be16s(deltas...)...)
+	b = append(b, be16s(rangeOffsets...)...)
+	b = append(b, be16s(arrays...)...)
+	binary.BigEndian.PutUint16(b[2:], uint16(len(b)))
+	return b
+}
+
+// cmapFormat12 writes a format 12 subtable, one group for each run of consecutive characters and glyphs
+func cmapFormat12(runes []rune, glyphs map[rune]GlyphID) []byte {
+	groups := []byte{}
+	numGroups := 0
+	for i := 0; i < len(runes); {
+		j := i + 1
+		for j < len(runes) && runes[j] == runes[j-1]+1 && glyphs[runes[j]] == glyphs[runes[j-1]]+1 {
+			j++
+		}
+		groups = append(groups, be32(uint32(runes[i]), uint32(runes[j-1]), uint32(glyphs[runes[i]]))...)
+		numGroups++
+		i = j
+	}
+	b := append(be16s(12, 0), be32(uint32(16+len(groups)), 0, uint32(numGroups))...)
+	return append(b, groups...)
+}
+
+// withCharIndexes returns a copy of the OS/2 table with the first and last characters of the subset
+func withCharIndexes(os2 []byte, glyphs map[rune]GlyphID) []byte {
+	first, last := rune(0xFFFF), rune(0)
+	for r := range glyphs {
+		if r < first {
+			first = r
+		}
+		if r > last {
+			last = r
+		}
+	}
+	if last > 0xFFFF {
+		last = 0xFFFF
+	}
+	os2 = append([]byte{}, os2...)
+	binary.BigEndian.PutUint16(os2[64:], uint16(first))
+	binary.BigEndian.PutUint16(os2[66:], uint16(last))
+	return os2
+}
+
+// be32 encodes values as big endian uint32
+func be32(values ...uint32) []byte {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
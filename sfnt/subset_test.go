@@ -0,0 +1,182 @@
+package sfnt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// glyfFont is testFont with 4 glyphs: an empty glyph, two triangles, and a composite of the second triangle. A GSUB
+// lookup substitutes the second triangle for the first.
+func glyfFont(t testing.TB) []byte {
+	t.Helper()
+	f, err := Parse(testFont())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := map[string][]byte{}
+	for _, tr := range f.Tables {
+		tables[tr.Tag], _ = f.Table(tr.Tag)
+	}
+
+	triangle := wantGlyf[:24]
+	composite := append([]byte{}, wantGlyf[24:40]...)
+	composite[13] = 2
+	tables["glyf"] = append(append(append([]byte{}, triangle...), triangle...), composite...)
+	tables["loca"] = be(uint16(0), uint16(0), uint16(12), uint16(24), uint16(32))
+	tables["hmtx"] = be(uint16(500), int16(0), uint16(600), int16(10), uint16(600), int16(10), uint16(600), int16(15))
+	tables["GSUB"] = be(uint16(1), uint16(0), uint16(0), uint16(0), uint16(10),
+		// lookup list, lookup, then a single substitution of glyph 1 by 2 and its coverage
+		uint16(1), uint16(4),
+		uint16(1), uint16(0), uint16(1), uint16(8),
+		uint16(2), uint16(8), uint16(1), uint16(2), uint16(1), uint16(1), uint16(1))
+	// variations of glyphs 1 and 3, with short offsets
+	tables["gvar"] = be(uint16(1), uint16(0), uint16(1), uint16(0), uint32(20), uint16(4), uint16(0), uint32(30),
+		uint16(0), uint16(0), uint16(1), uint16(1), uint16(3), "abcdef")
+	return Build(TrueType, tables)
+}
+
+// runesOf returns a Subset predicate keeping the characters of text
+func runesOf(text string) func(rune) bool {
+	return func(r rune) bool {
+		return strings.ContainsRune(text, r)
+	}
+}
+
+func TestSubset(t *testing.T) {
+	data, err := Subset(glyfFont(t), runesOf("C"), &SubsetOptions{DropLayout: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.VerifyChecksums(); err != nil {
+		t.Error(err)
+	}
+	if f.HasTable("GSUB") || !f.HasTable("fvar") {
+		t.Errorf("bad tables %+v", f.Tables)
+	}
+	info, err := f.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.NumGlyphs != 3 || len(info.Runes) != 1 || info.Runes[0] != 'C' {
+		t.Errorf("got %d glyphs, runes %q", info.NumGlyphs, info.Runes)
+	}
+	cmap, _ := f.Cmap()
+	if g, _ := cmap.Lookup('C'); g != 2 {
+		t.Errorf("C: got glyph %d, want 2", g)
+	}
+	if os2, _ := f.OS2(); os2.FirstCharIndex != 'C' || os2.LastCharIndex != 'C' {
+		t.Errorf("got char indexes %x-%x", os2.FirstCharIndex, os2.LastCharIndex)
+	}
+
+	composite := append([]byte{}, wantGlyf[24:40]...)
+	composite[13] = 1
+	tests := map[string][]byte{
+		"glyf": append(append([]byte{}, wantGlyf[:24]...), composite...),
+		"loca": be(uint16(0), uint16(0), uint16(12), uint16(20)),
+		"hmtx": be(uint16(500), int16(0), uint16(600), int16(10), int16(15)),
+		"gvar": be(uint16(1), uint16(0), uint16(1), uint16(0), uint32(36), uint16(3), uint16(1), uint32(36),
+			uint32(0), uint32(0), uint32(0), uint32(4), "cdef"),
+	}
+	for tag, want := range tests {
+		if got := mustTable(t, f, tag); !bytes.Equal(got, want) {
+			t.Errorf("%s:\ngot  %x\nwant %x", tag, got, want)
+		}
+	}
+	if hhea, _ := f.Hhea(); hhea.NumberOfHMetrics != 2 {
+		t.Errorf("got %d horizontal metrics, want 2", hhea.NumberOfHMetrics)
+	}
+}
+
+func TestSubsetKeepLayout(t *testing.T) {
+	font := glyfFont(t)
+	data, err := Subset(font, runesOf("A"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, _ := Parse(font)
+	for _, tag := range []string{"GSUB", "hmtx", "maxp"} {
+		if !bytes.Equal(mustTable(t, f, tag), mustTable(t, orig, tag)) {
+			t.Errorf("%s should not change", tag)
+		}
+	}
+
+	// glyph 2 is kept for GSUB, glyph 3 is emptied
+	want := append(append([]byte{}, wantGlyf[:24]...), wantGlyf[:24]...)
+	if got := mustTable(t, f, "glyf"); !bytes.Equal(got, want) {
+		t.Errorf("glyf:\ngot  %x\nwant %x", got, want)
+	}
+	cmap, _ := f.Cmap()
+	if g, _ := cmap.Lookup('A'); g != 1 || cmap.Len() != 1 {
+		t.Errorf("got glyph %d for A, %d characters", g, cmap.Len())
+	}
+
+	cff := Build(OpenType, map[string][]byte{"head": mustTable(t, f, "head")})
+	if _, err := Subset(cff, runesOf("A"), nil); !errors.Is(err, ErrFormat) {
+		t.Errorf("got %v, want ErrFormat", err)
+	}
+}
+
+func TestBuildCmap(t *testing.T) {
+	glyphs := map[rune]GlyphID{0x1F600: 1}
+	for r := rune(0x20); r < 0x7F; r++ {
+		glyphs[r] = GlyphID(r*7)%50 + 1
+	}
+	for r := rune(0x100); r < 0x110; r++ {
+		glyphs[r] = GlyphID(r - 0x100 + 60)
+	}
+	glyphs[0xFFFE] = 3
+
+	for _, symbol := range []bool{false, true} {
+		f, err := Parse(Build(TrueType, map[string][]byte{"cmap": buildCmap(glyphs, symbol)}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmap, err := f.Cmap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if symbol != (cmap.EncodingID == 0) {
+			t.Errorf("got encoding %d", cmap.EncodingID)
+		}
+		for r, want := range glyphs {
+			if r > 0xFFFF && symbol {
+				continue
+			}
+			if got, ok := cmap.Lookup(r); !ok || got != want {
+				t.Errorf("%U: got glyph %d, want %d", r, got, want)
+			}
+		}
+		if _, ok := cmap.Lookup(0xFFFF); ok {
+			t.Errorf("U+FFFF should not be mapped")
+		}
+	}
+}
+
+func FuzzSubset(f *testing.F) {
+	f.Add(glyfFont(f), "AC", false)
+	f.Add(glyfFont(f), "Bé", true)
+
+	f.Fuzz(func(t *testing.T, data []byte, text string, dropLayout bool) {
+		out, err := Subset(data, runesOf(text), &SubsetOptions{DropLayout: dropLayout})
+		if err != nil {
+			return
+		}
+		font, err := Parse(out)
+		if err != nil {
+			t.Fatalf("cannot parse subset: %v", err)
+		}
+		if _, err := font.Cmap(); err != nil {
+			t.Fatalf("cannot decode cmap of subset: %v", err)
+		}
+	})
+}
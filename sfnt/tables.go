@@ -0,0 +1,401 @@
+package sfnt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Head is the font header table
+type Head struct {
+	// FontRevision is set by the font manufacturer, such as 2.001
+	FontRevision float64   `json:"fontRevision"`
+	Flags        uint16    `json:"flags"`
+	UnitsPerEm   uint16    `json:"unitsPerEm"`
+	Created      time.Time `json:"created"`
+	Modified     time.Time `json:"modified"`
+	XMin         int16     `json:"xMin"`
+	YMin         int16     `json:"yMin"`
+	XMax         int16     `json:"xMax"`
+	YMax         int16     `json:"yMax"`
+	// MacStyle has bit 0 set for bold and bit 1 set for italic
+	MacStyle uint16 `json:"macStyle"`
+	// IndexToLocFormat is 0 for short loca offsets and 1 for long ones
+	IndexToLocFormat int16 `json:"indexToLocFormat"`
+}
+
+// headMagic is the magic number of the head table
+const headMagic = 0x5F0F3CF5
+
+// macEpoch is the origin of LONGDATETIME values
+var macEpoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Head decodes the head table
+func (f *Font) Head() (*Head, error) {
+	b, err := f.table("head", 54)
+	if err != nil {
+		return nil, err
+	}
+	if u32(b, 12) != headMagic {
+		return nil, fmt.Errorf("%w: bad head magic number", ErrFormat)
+	}
+	return &Head{
+		FontRevision:     round3(fixed(b, 4)),
+		Flags:            u16(b, 16),
+		UnitsPerEm:       u16(b, 18),
+		Created:          longDateTime(b, 20),
+		Modified:         longDateTime(b, 28),
+		XMin:             i16(b, 36),
+		YMin:             i16(b, 38),
+		XMax:             i16(b, 40),
+		YMax:             i16(b, 42),
+		MacStyle:         u16(b, 44),
+		IndexToLocFormat: i16(b, 50),
+	}, nil
+}
+
+// longDateTime reads seconds since 1904
+func longDateTime(b []byte, off int) time.Time {
+	secs := int64(u32(b, off))<<32 | int64(u32(b, off+4))
+	return macEpoch.Add(time.Duration(secs) * time.Second)
+}
+
+// Hhea is the horizontal header table
+type Hhea struct {
+	Ascender            int16  `json:"ascender"`
+	Descender           int16  `json:"descender"`
+	LineGap             int16  `json:"lineGap"`
+	AdvanceWidthMax     uint16 `json:"advanceWidthMax"`
+	MinLeftSideBearing  int16  `json:"minLeftSideBearing"`
+	MinRightSideBearing int16  `json:"minRightSideBearing"`
+	XMaxExtent          int16  `json:"xMaxExtent"`
+	CaretSlopeRise      int16  `json:"caretSlopeRise"`
+	CaretSlopeRun       int16  `json:"caretSlopeRun"`
+	CaretOffset         int16  `json:"caretOffset"`
+	NumberOfHMetrics    uint16 `json:"numberOfHMetrics"`
+}
+
+// Hhea decodes the hhea table
+func (f *Font) Hhea() (*Hhea, error) {
+	b, err := f.table("hhea", 36)
+	if err != nil {
+		return nil, err
+	}
+	return &Hhea{
+		Ascender:            i16(b, 4),
+		Descender:           i16(b, 6),
+		LineGap:             i16(b, 8),
+		AdvanceWidthMax:     u16(b, 10),
+		MinLeftSideBearing:  i16(b, 12),
+		MinRightSideBearing: i16(b, 14),
+		XMaxExtent:          i16(b, 16),
+		CaretSlopeRise:      i16(b, 18),
+		CaretSlopeRun:       i16(b, 20),
+		CaretOffset:         i16(b, 22),
+		NumberOfHMetrics:    u16(b, 34),
+	}, nil
+}
+
+// NumGlyphs returns the number of glyphs, from the maxp table
+func (f *Font) NumGlyphs() (int, error) {
+	b, err := f.table("maxp", 6)
+	if err != nil {
+		return 0, err
+	}
+	return int(u16(b, 4)), nil
+}
+
+// OS2 is the OS/2 and Windows metrics table. Fields that the table version lacks are zero.
+type OS2 struct {
+	Version uint16 `json:"version"`
+	// XAvgCharWidth is the average width of lowercase letters or, from version 3, of all glyphs
+	XAvgCharWidth int16 `json:"xAvgCharWidth"`
+	// WeightClass is the weight, such as 400 for regular and 700 for bold
+	WeightClass uint16 `json:"weightClass"`
+	// WidthClass is the width, from 1 (ultra condensed) to 9 (ultra expanded)
+	WidthClass uint16 `json:"widthClass"`
+	// FsType are the embedding permissions
+	FsType uint16   `json:"fsType"`
+	Panose [10]byte `json:"panose"`
+	// UnicodeRange are the bits of the Unicode blocks the font claims to support
+	UnicodeRange [4]uint32 `json:"unicodeRange"`
+	VendorID     string    `json:"vendorId"`
+	// FsSelection has bit 0 set for italic, bit 5 for bold, bit 6 for regular and bit 7 for USE_TYPO_METRICS
+	FsSelection      uint16    `json:"fsSelection"`
+	FirstCharIndex   uint16    `json:"firstCharIndex"`
+	LastCharIndex    uint16    `json:"lastCharIndex"`
+	TypoAscender     int16     `json:"typoAscender"`
+	TypoDescender    int16     `json:"typoDescender"`
+	TypoLineGap      int16     `json:"typoLineGap"`
+	WinAscent        uint16    `json:"winAscent"`
+	WinDescent       uint16    `json:"winDescent"`
+	CodePageRange    [2]uint32 `json:"codePageRange"`
+	XHeight          int16     `json:"xHeight"`
+	CapHeight        int16     `json:"capHeight"`
+	DefaultChar      uint16    `json:"defaultChar"`
+	BreakChar        uint16    `json:"breakChar"`
+	MaxContext       uint16    `json:"maxContext"`
+	LowerOpticalSize uint16    `json:"lowerOpticalPointSize,omitempty"`
+	UpperOpticalSize uint16    `json:"upperOpticalPointSize,omitempty"`
+}
+
+// OS2 decodes the OS/2 table
+func (f *Font) OS2() (*OS2, error) {
+	// version 0 tables of old Apple fonts stop before sTypoAscender
+	b, err := f.table("OS/2", 68)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &OS2{
+		Version:        u16(b, 0),
+		XAvgCharWidth:  i16(b, 2),
+		WeightClass:    u16(b, 4),
+		WidthClass:     u16(b, 6),
+		FsType:         u16(b, 8),
+		VendorID:       strings.TrimRight(string(b[58:62]), " \x00"),
+		FsSelection:    u16(b, 62),
+		FirstCharIndex: u16(b, 64),
+		LastCharIndex:  u16(b, 66),
+	}
+	copy(t.Panose[:], b[32:42])
+	for i := range t.UnicodeRange {
+		t.UnicodeRange[i] = u32(b, 42+4*i)
+	}
+
+	if len(b) >= 78 {
+		t.TypoAscender = i16(b, 68)
+		t.TypoDescender = i16(b, 70)
+		t.TypoLineGap = i16(b, 72)
+		t.WinAscent = u16(b, 74)
+		t.WinDescent = u16(b, 76)
+	}
+	if t.Version >= 1 && len(b) >= 86 {
+		t.CodePageRange[0] = u32(b, 78)
+		t.CodePageRange[1] = u32(b, 82)
+	}
+	if t.Version >= 2 && len(b) >= 96 {
+		t.XHeight = i16(b, 86)
+		t.CapHeight = i16(b, 88)
+		t.DefaultChar = u16(b, 90)
+		t.BreakChar = u16(b, 92)
+		t.MaxContext = u16(b, 94)
+	}
+	if t.Version >= 5 && len(b) >= 100 {
+		t.LowerOpticalSize = u16(b, 96)
+		t.UpperOpticalSize = u16(b, 98)
+	}
+	return t, nil
+}
+
+// Post is the header of the PostScript table. Glyph names are not decoded.
+type Post struct {
+	Version            float64 `json:"version"`
+	ItalicAngle        float64 `json:"italicAngle"`
+	UnderlinePosition  int16   `json:"underlinePosition"`
+	UnderlineThickness int16   `json:"underlineThickness"`
+	IsFixedPitch       bool    `json:"isFixedPitch"`
+}
+
+// Post decodes the post table
+func (f *Font) Post() (*Post, error) {
+	b, err := f.table("post", 32)
+	if err != nil {
+		return nil, err
+	}
+	return &Post{
+		// version 2.5 is stored as 0x00025000, not as a 16.16 fraction
+		Version:            float64(u16(b, 0)) + float64(u16(b, 2)>>12)/10,
+		ItalicAngle:        round3(fixed(b, 4)),
+		UnderlinePosition:  i16(b, 8),
+		UnderlineThickness: i16(b, 10),
+		IsFixedPitch:       u32(b, 12) != 0,
+	}, nil
+}
+
+// Name IDs of the name table
+const (
+	NameCopyright                  uint16 = 0
+	NameFamily                     uint16 = 1
+	NameSubfamily                  uint16 = 2
+	NameUniqueID                   uint16 = 3
+	NameFull                       uint16 = 4
+	NameVersion                    uint16 = 5
+	NamePostScript                 uint16 = 6
+	NameLicense                    uint16 = 13
+	NameLicenseURL                 uint16 = 14
+	NameTypographicFamily          uint16 = 16
+	NameTypographicSubfamily       uint16 = 17
+	NameVariationsPostScriptPrefix uint16 = 25
+)
+
+// NameRecord is a string of the name table
+type NameRecord struct {
+	PlatformID uint16 `json:"platformId"`
+	EncodingID uint16 `json:"encodingId"`
+	LanguageID uint16 `json:"languageId"`
+	NameID     uint16 `json:"nameId"`
+	Value      string `json:"value"`
+}
+
+// Names is the decoded name table
+type Names []NameRecord
+
+// Get returns the string with the name ID, preferring English Windows strings, or an empty string
+func (ns Names) Get(nameID uint16) string {
+	best, bestScore := "", 0
+	for _, n := range ns {
+		if n.NameID != nameID {
+			continue
+		}
+		score := 1
+		switch {
+		case n.PlatformID == 3 && n.LanguageID == 0x409:
+			score = 4
+		case n.PlatformID == 3:
+			score = 3
+		case n.PlatformID == 0:
+			score = 2
+		}
+		if score > bestScore {
+			best, bestScore = n.Value, score
+		}
+	}
+	return best
+}
+
+// Names decodes the name table. Strings in encodings other than Unicode and Mac Roman are skipped.
+func (f *Font) Names() (Names, error) {
+	b, err := f.table("name", 6)
+	if err != nil {
+		return nil, err
+	}
+
+	count := int(u16(b, 2))
+	storage := int(u16(b, 4))
+	if len(b) < 6+12*count {
+		return nil, fmt.Errorf("%w: name records truncated", ErrFormat)
+	}
+
+	result := Names{}
+	for i := 0; i < count; i++ {
+		p := 6 + 12*i
+		n := NameRecord{
+			PlatformID: u16(b, p),
+			EncodingID: u16(b, p+2),
+			LanguageID: u16(b, p+4),
+			NameID:     u16(b, p+6),
+		}
+		length, offset := int(u16(b, p+8)), storage+int(u16(b, p+10))
+		if offset+length > len(b) {
+			return nil, fmt.Errorf("%w: name string out of bounds", ErrFormat)
+		}
+		raw := b[offset : offset+length]
+
+		switch {
+		case n.PlatformID == 0, n.PlatformID == 3 && (n.EncodingID == 0 || n.EncodingID == 1 || n.EncodingID == 10):
+			n.Value = decodeUTF16(raw)
+		case n.PlatformID == 1 && n.EncodingID == 0:
+			n.Value = decodeMacRoman(raw)
+		default:
+			continue
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func decodeUTF16(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = u16(b, 2*i)
+	}
+	return string(utf16.Decode(units))
+}
+
+// macRomanHigh maps the bytes 0x80 to 0xFF of Mac Roman
+var macRomanHigh = []rune("ÄÅÇÉÑÖÜáàâäãåçéèêëíìîïñóòôöõúùûü†°¢£§•¶ß®©™´¨≠ÆØ∞±≤≥¥µ∂∑∏π∫ªºΩæø¿¡¬√ƒ≈∆«»… ÀÃÕŒœ–—“”‘’÷◊ÿŸ⁄€‹›ﬁﬂ‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ")
+
+func decodeMacRoman(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		if c < 0x80 {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteRune(macRomanHigh[c-0x80])
+		}
+	}
+	return sb.String()
+}
+
+// Axis is a variation axis of the fvar table
+type Axis struct {
+	// Tag is the axis tag, such as wght or ital
+	Tag     string  `json:"tag"`
+	Min     float64 `json:"min"`
+	Default float64 `json:"default"`
+	Max     float64 `json:"max"`
+	// Hidden is true for axes that should not be exposed to users
+	Hidden bool   `json:"hidden,omitempty"`
+	NameID uint16 `json:"nameId"`
+}
+
+// Instance is a named instance of the fvar table, such as Bold
+type Instance struct {
+	SubfamilyNameID uint16 `json:"subfamilyNameId"`
+	// PostScriptNameID is 0 if the instance has no PostScript name
+	PostScriptNameID uint16 `json:"postScriptNameId,omitempty"`
+	// Coordinates has one value per axis
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// Fvar is the font variations table
+type Fvar struct {
+	Axes      []Axis     `json:"axes"`
+	Instances []Instance `json:"instances"`
+}
+
+// Fvar decodes the fvar table of a variable font
+func (f *Font) Fvar() (*Fvar, error) {
+	b, err := f.table("fvar", 16)
+	if err != nil {
+		return nil, err
+	}
+
+	axesOffset := int(u16(b, 4))
+	axisCount, axisSize := int(u16(b, 8)), int(u16(b, 10))
+	instanceCount, instanceSize := int(u16(b, 12)), int(u16(b, 14))
+	if axisSize < 20 || instanceSize < 4+4*axisCount {
+		return nil, fmt.Errorf("%w: bad fvar record size", ErrFormat)
+	}
+	instancesOffset := axesOffset + axisCount*axisSize
+	if instancesOffset+instanceCount*instanceSize > len(b) {
+		return nil, fmt.Errorf("%w: fvar records truncated", ErrFormat)
+	}
+
+	t := &Fvar{Axes: []Axis{}, Instances: []Instance{}}
+	for i := 0; i < axisCount; i++ {
+		p := axesOffset + i*axisSize
+		t.Axes = append(t.Axes, Axis{
+			Tag:     string(b[p : p+4]),
+			Min:     round3(fixed(b, p+4)),
+			Default: round3(fixed(b, p+8)),
+			Max:     round3(fixed(b, p+12)),
+			Hidden:  u16(b, p+16)&1 != 0,
+			NameID:  u16(b, p+18),
+		})
+	}
+	for i := 0; i < instanceCount; i++ {
+		p := instancesOffset + i*instanceSize
+		inst := Instance{SubfamilyNameID: u16(b, p), Coordinates: make([]float64, axisCount)}
+		for j := range inst.Coordinates {
+			inst.Coordinates[j] = round3(fixed(b, p+4+4*j))
+		}
+		if instanceSize >= 6+4*axisCount {
+			inst.PostScriptNameID = u16(b, p+4+4*axisCount)
+		}
+		t.Instances = append(t.Instances, inst)
+	}
+	return t, nil
+}
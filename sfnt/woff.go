@@ -0,0 +1,389 @@
+package sfnt
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	woffSignature  = 0x774F4646 // wOFF
+	woff2Signature = 0x774F4632 // wOF2
+	ttcSignature   = 0x74746366 // ttcf
+)
+
+// maxSfntSize caps the decoded size of a WOFF or WOFF2 file. The largest fonts are tens of megabytes, while a small
+// file could otherwise claim gigabytes of decompressed data.
+const maxSfntSize = 1 << 28
+
+// WOFF is a WOFF or WOFF2 file. ParseWOFF reads only its header and table directory, so that the font version, flavor
+// and tables can be known without decompressing anything.
+type WOFF struct {
+	// Format is woff or woff2
+	Format string `json:"format"`
+	// Flavor is the sfnt version of the font inside: TrueType or OpenType
+	Flavor uint32 `json:"flavor"`
+	// Length is the size of the file
+	Length uint32 `json:"length"`
+	// TotalSfntSize is the size of the decoded font. Files whose tables do not fit in it are rejected.
+	TotalSfntSize uint32 `json:"totalSfntSize"`
+	// TotalCompressedSize is the size of the brotli stream of a woff2 file
+	TotalCompressedSize uint32 `json:"totalCompressedSize,omitempty"`
+	// MajorVersion and MinorVersion are the version of the font, such as 1.1
+	MajorVersion   uint16      `json:"majorVersion"`
+	MinorVersion   uint16      `json:"minorVersion"`
+	MetaOffset     uint32      `json:"metaOffset"`
+	MetaLength     uint32      `json:"metaLength"`
+	MetaOrigLength uint32      `json:"metaOrigLength"`
+	PrivOffset     uint32      `json:"privOffset"`
+	PrivLength     uint32      `json:"privLength"`
+	Tables         []WOFFTable `json:"tables"`
+	data           []byte
+	// streamOffset is the offset of the brotli stream of a woff2 file
+	streamOffset int
+}
+
+// WOFFTable is an entry of the table directory of a WOFF or WOFF2 file
+type WOFFTable struct {
+	Tag string `json:"tag"`
+	// Offset is the offset of the table in the file for woff, and in the decompressed stream for woff2
+	Offset uint32 `json:"offset"`
+	// CompLength is the compressed length for woff, and the length in the decompressed stream for woff2
+	CompLength uint32 `json:"compLength"`
+	OrigLength uint32 `json:"origLength"`
+	// OrigChecksum is the checksum of the table in the original font. woff2 files have none.
+	OrigChecksum uint32 `json:"origChecksum,omitempty"`
+	// Transform is the woff2 transformation version
+	Transform uint8 `json:"transform,omitempty"`
+	// Transformed is true for woff2 tables stored in a transformed form, such as glyf
+	Transformed bool `json:"transformed,omitempty"`
+}
+
+// DecodeOptions are the optional checks done while decoding
+type DecodeOptions struct {
+	// VerifyChecksums checks that each table of a woff file or font matches its checksum. woff2 files carry no
+	// checksums: the checksums of the decoded font are computed.
+	VerifyChecksums bool
+	// VerifyBlocks checks the layout of a woff or woff2 file: the length in the header, the position of the tables,
+	// and that the extended metadata block, if any, is well-formed XML and the private data block is at the end.
+	VerifyBlocks bool
+}
+
+// Decode returns the font file in data, decoding WOFF and WOFF2 files. Fonts that are not compressed are returned as
+// is. opts may be nil.
+func Decode(data []byte, opts *DecodeOptions) ([]byte, error) {
+	if len(data) >= 4 {
+		switch u32(data, 0) {
+		case woffSignature, woff2Signature:
+			w, err := ParseWOFF(data)
+			if err != nil {
+				return nil, err
+			}
+			return w.Decode(opts)
+		}
+	}
+
+	f, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if opts != nil && opts.VerifyChecksums {
+		if err := f.VerifyChecksums(); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// ParseWOFF reads the header and table directory of a WOFF or WOFF2 file
+func ParseWOFF(data []byte) (*WOFF, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: file too short", ErrFormat)
+	}
+
+	var w *WOFF
+	var err error
+	switch u32(data, 0) {
+	case woffSignature:
+		w, err = parseWOFF1(data)
+	case woff2Signature:
+		w, err = parseWOFF2(data)
+	default:
+		return nil, fmt.Errorf("%w: not a woff file", ErrFormat)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch w.Flavor {
+	case TrueType, OpenType, AppleTrueType:
+	case ttcSignature:
+		return nil, fmt.Errorf("%w: font collections are not supported", ErrFormat)
+	default:
+		return nil, fmt.Errorf("%w: unknown flavor %08x", ErrFormat, w.Flavor)
+	}
+	if int64(w.Length) > int64(len(data)) {
+		return nil, fmt.Errorf("%w: file truncated to %d bytes, header says %d", ErrFormat, len(data), w.Length)
+	}
+	return w, nil
+}
+
+// Decode decompresses the tables and returns the font file
+func (w *WOFF) Decode(opts *DecodeOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &DecodeOptions{}
+	}
+	if opts.VerifyBlocks {
+		if err := w.verifyBlocks(); err != nil {
+			return nil, err
+		}
+	}
+
+	if w.Format == "woff2" {
+		return w.decodeWOFF2()
+	}
+
+	tables := map[string][]byte{}
+	for _, t := range w.Tables {
+		data, err := w.table(t)
+		if err != nil {
+			return nil, err
+		}
+		if opts.VerifyChecksums {
+			if sum := tableChecksum(t.Tag, data); sum != t.OrigChecksum {
+				return nil, fmt.Errorf("%w: table %s has checksum %08x, want %08x", ErrChecksum, t.Tag, sum, t.OrigChecksum)
+			}
+		}
+		tables[t.Tag] = data
+	}
+	return Build(w.Flavor, tables), nil
+}
+
+// Font decodes the file and parses the font
+func (w *WOFF) Font() (*Font, error) {
+	data, err := w.Decode(nil)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Metadata returns the decompressed extended metadata block, an XML document, or nil if the file has none. The font
+// tables are not decompressed.
+func (w *WOFF) Metadata() ([]byte, error) {
+	if w.MetaLength == 0 {
+		return nil, nil
+	}
+	end := int64(w.MetaOffset) + int64(w.MetaLength)
+	if end > int64(len(w.data)) {
+		return nil, fmt.Errorf("%w: metadata block out of bounds", ErrFormat)
+	}
+
+	compressed := w.data[w.MetaOffset:end]
+	var r io.Reader
+	if w.Format == "woff2" {
+		r = newBrotliReader(compressed)
+	} else {
+		zr, err := zlib.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, fmt.Errorf("%w: metadata block: %v", ErrFormat, err)
+		}
+		r = zr
+	}
+	return readAllN(r, int(w.MetaOrigLength), "metadata block")
+}
+
+// Private returns the private data block, or nil if the file has none
+func (w *WOFF) Private() []byte {
+	end := int64(w.PrivOffset) + int64(w.PrivLength)
+	if w.PrivLength == 0 || end > int64(len(w.data)) {
+		return nil
+	}
+	return w.data[w.PrivOffset:end]
+}
+
+// parseWOFF1 reads the header and table directory of a WOFF file
+func parseWOFF1(data []byte) (*WOFF, error) {
+	if len(data) < 44 {
+		return nil, fmt.Errorf("%w: woff header truncated", ErrFormat)
+	}
+	w := &WOFF{
+		Format:         "woff",
+		Flavor:         u32(data, 4),
+		Length:         u32(data, 8),
+		TotalSfntSize:  u32(data, 16),
+		MajorVersion:   u16(data, 20),
+		MinorVersion:   u16(data, 22),
+		MetaOffset:     u32(data, 24),
+		MetaLength:     u32(data, 28),
+		MetaOrigLength: u32(data, 32),
+		PrivOffset:     u32(data, 36),
+		PrivLength:     u32(data, 40),
+		data:           data,
+	}
+	if u16(data, 14) != 0 {
+		return nil, fmt.Errorf("%w: reserved woff header field is not zero", ErrFormat)
+	}
+
+	numTables := int(u16(data, 12))
+	if len(data) < 44+20*numTables {
+		return nil, fmt.Errorf("%w: woff table directory truncated", ErrFormat)
+	}
+	var decoded int64
+	for i := 0; i < numTables; i++ {
+		p := 44 + 20*i
+		t := WOFFTable{
+			Tag:          string(data[p : p+4]),
+			Offset:       u32(data, p+4),
+			CompLength:   u32(data, p+8),
+			OrigLength:   u32(data, p+12),
+			OrigChecksum: u32(data, p+16),
+		}
+		if int64(t.Offset)+int64(t.CompLength) > int64(len(data)) {
+			return nil, fmt.Errorf("%w: table %s out of bounds", ErrFormat, t.Tag)
+		}
+		if t.CompLength > t.OrigLength {
+			return nil, fmt.Errorf("%w: table %s is larger compressed", ErrFormat, t.Tag)
+		}
+		decoded += int64(t.OrigLength)
+		w.Tables = append(w.Tables, t)
+	}
+	if err := w.verifySfntSize(decoded); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// verifySfntSize checks that the decoded font is no larger than maxSfntSize, and that its tables, n bytes once
+// decompressed, fit in it. It fails before anything is decompressed.
+func (w *WOFF) verifySfntSize(n int64) error {
+	if w.TotalSfntSize > maxSfntSize {
+		return fmt.Errorf("%w: font is %d bytes decoded, more than %d", ErrFormat, w.TotalSfntSize, maxSfntSize)
+	}
+	if n > int64(w.TotalSfntSize) {
+		return fmt.Errorf("%w: tables are %d bytes decoded, more than the font size %d", ErrFormat, n, w.TotalSfntSize)
+	}
+	return nil
+}
+
+// table returns the decompressed data of a table of a WOFF file
+func (w *WOFF) table(t WOFFTable) ([]byte, error) {
+	data := w.data[t.Offset : t.Offset+t.CompLength]
+	if t.CompLength == t.OrigLength {
+		return data, nil
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: table %s: %v", ErrFormat, t.Tag, err)
+	}
+	return readAllN(zr, int(t.OrigLength), "table "+t.Tag)
+}
+
+// readAllN reads exactly n bytes from r, which must have no more. At most n+1 bytes are read, and n cannot be more than
+// maxSfntSize, so that a small compressed stream cannot use up the memory.
+func readAllN(r io.Reader, n int, what string) ([]byte, error) {
+	if n > maxSfntSize {
+		return nil, fmt.Errorf("%w: %s is %d bytes decoded, more than %d", ErrFormat, what, n, maxSfntSize)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(n)+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrFormat, what, err)
+	}
+	if len(data) != n {
+		return nil, fmt.Errorf("%w: %s decompresses to %d bytes, want %d", ErrFormat, what, len(data), n)
+	}
+	return data, nil
+}
+
+// verifyBlocks checks that the file length is right, that the tables follow the table directory without overlapping,
+// and that the metadata and private blocks follow the tables in this order, 4-byte aligned, up to the end of the file
+func (w *WOFF) verifyBlocks() error {
+	if int(w.Length) != len(w.data) {
+		return fmt.Errorf("%w: file is %d bytes, header says %d", ErrFormat, len(w.data), w.Length)
+	}
+
+	// end is the end of the table data
+	var end int64
+	if w.Format == "woff2" {
+		end = int64(w.streamOffset) + int64(w.TotalCompressedSize)
+	} else {
+		tables := append([]WOFFTable{}, w.Tables...)
+		sort.Slice(tables, func(i, j int) bool {
+			return tables[i].Offset < tables[j].Offset
+		})
+		end = int64(44 + 20*len(tables))
+		for _, t := range tables {
+			if t.Offset%4 != 0 || int64(t.Offset) < end {
+				return fmt.Errorf("%w: table %s is misplaced", ErrFormat, t.Tag)
+			}
+			end = int64(t.Offset) + int64(t.CompLength)
+		}
+	}
+
+	if w.MetaLength == 0 && (w.MetaOffset != 0 || w.MetaOrigLength != 0) {
+		return fmt.Errorf("%w: empty metadata block with an offset", ErrFormat)
+	}
+	if w.MetaLength > 0 {
+		if int64(w.MetaOffset) != int64(pad4(int(end))) {
+			return fmt.Errorf("%w: metadata block does not follow the tables", ErrFormat)
+		}
+		end = int64(w.MetaOffset) + int64(w.MetaLength)
+
+		meta, err := w.Metadata()
+		if err != nil {
+			return err
+		}
+		if err := checkMetadata(meta); err != nil {
+			return err
+		}
+	}
+
+	if w.PrivLength == 0 && w.PrivOffset != 0 {
+		return fmt.Errorf("%w: empty private data block with an offset", ErrFormat)
+	}
+	if w.PrivLength > 0 {
+		if int64(w.PrivOffset) != int64(pad4(int(end))) {
+			return fmt.Errorf("%w: private data block does not follow the tables and metadata", ErrFormat)
+		}
+		end = int64(w.PrivOffset) + int64(w.PrivLength)
+	}
+
+	// the last block is padded only if other blocks follow
+	if end != int64(len(w.data)) && int64(pad4(int(end))) != int64(len(w.data)) {
+		return fmt.Errorf("%w: %d bytes of unexpected data at the end of the file", ErrFormat, int64(len(w.data))-end)
+	}
+	return nil
+}
+
+// checkMetadata checks that the extended metadata is well-formed UTF-8 XML with a metadata root element
+func checkMetadata(meta []byte) error {
+	if !utf8.Valid(meta) {
+		return fmt.Errorf("%w: metadata is not UTF-8", ErrFormat)
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(meta))
+	root := ""
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: metadata: %v", ErrFormat, err)
+		}
+		if se, ok := tok.(xml.StartElement); ok && root == "" {
+			root = se.Name.Local
+		}
+	}
+	if root != "metadata" {
+		return fmt.Errorf("%w: metadata root element is %q, want metadata", ErrFormat, root)
+	}
+	return nil
+}
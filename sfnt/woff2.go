@@ -0,0 +1,688 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// woff2KnownTags are the tags that the flags byte of a woff2 table directory entry refers to by index
+var woff2KnownTags = []string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post", "cvt ", "fpgm", "glyf", "loca", "prep", "CFF ",
+	"VORG", "EBDT", "EBLC", "gasp", "hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea", "vmtx", "BASE", "GDEF", "GPOS",
+	"GSUB", "EBSC", "JSTF", "MATH", "CBDT", "CBLC", "COLR", "CPAL", "SVG ", "sbix", "acnt", "avar", "bdat", "bloc",
+	"bsln", "cvar", "fdsc", "feat", "fmtx", "fvar", "gvar", "hsty", "just", "lcar", "mort", "morx", "opbd", "prop",
+	"trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+// woff2NullTransform is the transformation version of tables stored as is. It is 3 for glyf and loca, 0 for others.
+func woff2NullTransform(tag string) uint8 {
+	if tag == "glyf" || tag == "loca" {
+		return 3
+	}
+	return 0
+}
+
+// parseWOFF2 reads the header and table directory of a WOFF2 file
+func parseWOFF2(data []byte) (*WOFF, error) {
+	if len(data) < 48 {
+		return nil, fmt.Errorf("%w: woff2 header truncated", ErrFormat)
+	}
+	w := &WOFF{
+		Format:              "woff2",
+		Flavor:              u32(data, 4),
+		Length:              u32(data, 8),
+		TotalSfntSize:       u32(data, 16),
+		TotalCompressedSize: u32(data, 20),
+		MajorVersion:        u16(data, 24),
+		MinorVersion:        u16(data, 26),
+		MetaOffset:          u32(data, 28),
+		MetaLength:          u32(data, 32),
+		MetaOrigLength:      u32(data, 36),
+		PrivOffset:          u32(data, 40),
+		PrivLength:          u32(data, 44),
+		data:                data,
+	}
+	if u16(data, 14) != 0 {
+		return nil, fmt.Errorf("%w: reserved woff2 header field is not zero", ErrFormat)
+	}
+
+	r := &reader{b: data, off: 48}
+	numTables := int(u16(data, 12))
+	var offset uint32
+	for i := 0; i < numTables; i++ {
+		flags := r.u8()
+		t := WOFFTable{Transform: flags >> 6}
+		if flags&0x3F == 0x3F {
+			t.Tag = string(r.bytes(4))
+		} else if int(flags&0x3F) < len(woff2KnownTags) {
+			t.Tag = woff2KnownTags[flags&0x3F]
+		} else {
+			return nil, fmt.Errorf("%w: unknown woff2 tag index %d", ErrFormat, flags&0x3F)
+		}
+
+		t.OrigLength = r.base128()
+		t.CompLength = t.OrigLength
+		if t.Transform != woff2NullTransform(t.Tag) {
+			t.Transformed = true
+			t.CompLength = r.base128()
+			if t.Tag == "loca" && t.CompLength != 0 {
+				return nil, fmt.Errorf("%w: transformed loca table is not empty", ErrFormat)
+			}
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("%w: woff2 table directory: %v", ErrFormat, r.err)
+		}
+
+		t.Offset = offset
+		if uint64(offset)+uint64(t.CompLength) > 1<<32-1 {
+			return nil, fmt.Errorf("%w: woff2 tables too large", ErrFormat)
+		}
+		offset += t.CompLength
+		w.Tables = append(w.Tables, t)
+	}
+
+	if err := w.verifySfntSize(int64(w.streamLength())); err != nil {
+		return nil, err
+	}
+	if w.Flavor == ttcSignature {
+		return w, nil
+	}
+	w.streamOffset = r.off
+	if int64(w.streamOffset)+int64(w.TotalCompressedSize) > int64(len(data)) {
+		return nil, fmt.Errorf("%w: woff2 compressed data out of bounds", ErrFormat)
+	}
+	return w, nil
+}
+
+// streamLength returns the size of the decompressed stream of a WOFF2 file
+func (w *WOFF) streamLength() int {
+	if len(w.Tables) == 0 {
+		return 0
+	}
+	last := w.Tables[len(w.Tables)-1]
+	return int(last.Offset + last.CompLength)
+}
+
+// decodeWOFF2 decompresses the tables of a WOFF2 file, reverses their transformations and returns the font file
+func (w *WOFF) decodeWOFF2() ([]byte, error) {
+	compressed := w.data[w.streamOffset : w.streamOffset+int(w.TotalCompressedSize)]
+	stream, err := readAllN(newBrotliReader(compressed), w.streamLength(), "woff2 compressed data")
+	if err != nil {
+		return nil, err
+	}
+
+	tables := map[string][]byte{}
+	transformed := map[string]WOFFTable{}
+	for _, t := range w.Tables {
+		if _, ok := tables[t.Tag]; ok {
+			return nil, fmt.Errorf("%w: duplicate table %s", ErrFormat, t.Tag)
+		}
+		tables[t.Tag] = stream[t.Offset : t.Offset+t.CompLength]
+		if t.Transformed {
+			transformed[t.Tag] = t
+		}
+	}
+
+	if t, ok := transformed["glyf"]; ok {
+		if t.Transform != 0 {
+			return nil, fmt.Errorf("%w: unknown glyf transformation %d", ErrFormat, t.Transform)
+		}
+		if _, ok := transformed["loca"]; !ok {
+			return nil, fmt.Errorf("%w: glyf is transformed but loca is not", ErrFormat)
+		}
+		glyf, loca, err := reconstructGlyf(tables["glyf"])
+		if err != nil {
+			return nil, err
+		}
+		if len(loca) != int(transformed["loca"].OrigLength) {
+			return nil, fmt.Errorf("%w: loca is %d bytes, want %d", ErrFormat, len(loca), transformed["loca"].OrigLength)
+		}
+		tables["glyf"], tables["loca"] = glyf, loca
+		delete(transformed, "glyf")
+		delete(transformed, "loca")
+	}
+	if t, ok := transformed["hmtx"]; ok {
+		if t.Transform != 1 {
+			return nil, fmt.Errorf("%w: unknown hmtx transformation %d", ErrFormat, t.Transform)
+		}
+		hmtx, err := reconstructHmtx(tables)
+		if err != nil {
+			return nil, err
+		}
+		tables["hmtx"] = hmtx
+		delete(transformed, "hmtx")
+	}
+	for tag, t := range transformed {
+		return nil, fmt.Errorf("%w: unknown %s transformation %d", ErrFormat, tag, t.Transform)
+	}
+	return Build(w.Flavor, tables), nil
+}
+
+// Flags of simple glyphs
+const (
+	flagOnCurve       = 0x01
+	flagXShort        = 0x02
+	flagYShort        = 0x04
+	flagRepeat        = 0x08
+	flagXSame         = 0x10
+	flagYSame         = 0x20
+	flagOverlapSimple = 0x40
+)
+
+// Flags of composite glyph components
+const (
+	flagArgsAreWords     = 0x0001
+	flagHaveScale        = 0x0008
+	flagMoreComponents   = 0x0020
+	flagHaveXYScale      = 0x0040
+	flagHaveTwoByTwo     = 0x0080
+	flagHaveInstructions = 0x0100
+)
+
+// point is a point of a simple glyph
+type point struct {
+	x, y    int
+	onCurve bool
+}
+
+// reconstructGlyf reverses the woff2 glyf transformation and returns the glyf and loca tables
+func reconstructGlyf(data []byte) ([]byte, []byte, error) {
+	if len(data) < 36 {
+		return nil, nil, fmt.Errorf("%w: transformed glyf header truncated", ErrFormat)
+	}
+	optionFlags := u16(data, 2)
+	numGlyphs := int(u16(data, 4))
+	indexFormat := u16(data, 6)
+
+	// the streams follow the header in this order
+	streams := make([]*reader, 7)
+	off := 36
+	for i := range streams {
+		size := int(u32(data, 8+4*i))
+		if size < 0 || off+size > len(data) || off+size < off {
+			return nil, nil, fmt.Errorf("%w: transformed glyf stream out of bounds", ErrFormat)
+		}
+		streams[i] = &reader{b: data[off : off+size]}
+		off += size
+	}
+	nContours, nPoints, flags, glyphs, composites, bboxes, instructions :=
+		streams[0], streams[1], streams[2], streams[3], streams[4], streams[5], streams[6]
+
+	bitmapSize := 4 * ((numGlyphs + 31) / 32)
+	bboxBitmap := bboxes.bytes(bitmapSize)
+	var overlapBitmap []byte
+	if optionFlags&1 != 0 {
+		if off+(numGlyphs+7)/8 > len(data) {
+			return nil, nil, fmt.Errorf("%w: overlap bitmap truncated", ErrFormat)
+		}
+		overlapBitmap = data[off : off+(numGlyphs+7)/8]
+	}
+
+	glyf := []byte{}
+	offsets := make([]int, numGlyphs+1)
+	for i := 0; i < numGlyphs; i++ {
+		offsets[i] = len(glyf)
+		n := int(int16(nContours.u16()))
+		hasBBox := bboxBitmap != nil && bboxBitmap[i/8]&(0x80>>(i%8)) != 0
+
+		var glyph []byte
+		switch {
+		case n == 0:
+			if hasBBox {
+				return nil, nil, fmt.Errorf("%w: empty glyph %d has a bounding box", ErrFormat, i)
+			}
+		case n < 0:
+			if !hasBBox {
+				return nil, nil, fmt.Errorf("%w: composite glyph %d has no bounding box", ErrFormat, i)
+			}
+			glyph = be16s(0xFFFF)
+			glyph = append(glyph, bboxes.bytes(8)...)
+			components, haveInstructions := compositeGlyph(composites)
+			glyph = append(glyph, components...)
+			if haveInstructions {
+				length := glyphs.uint255()
+				glyph = append(glyph, be16s(length)...)
+				glyph = append(glyph, instructions.bytes(int(length))...)
+			}
+		default:
+			endPts := make([]uint16, n)
+			total := 0
+			for c := range endPts {
+				total += int(nPoints.uint255())
+				if total > 0xFFFF {
+					return nil, nil, fmt.Errorf("%w: glyph %d has too many points", ErrFormat, i)
+				}
+				endPts[c] = uint16(total - 1)
+			}
+			points := decodeTriplets(flags, glyphs, total)
+			length := glyphs.uint255()
+			instr := instructions.bytes(int(length))
+
+			var bbox []byte
+			if hasBBox {
+				bbox = bboxes.bytes(8)
+			} else {
+				bbox = pointsBBox(points)
+			}
+			overlap := overlapBitmap != nil && overlapBitmap[i/8]&(0x80>>(i%8)) != 0
+			glyph = simpleGlyph(endPts, bbox, instr, points, overlap)
+		}
+
+		for _, r := range streams {
+			if r.err != nil {
+				return nil, nil, fmt.Errorf("%w: glyph %d: %v", ErrFormat, i, r.err)
+			}
+		}
+		glyf = append(glyf, glyph...)
+		glyf = append(glyf, make([]byte, pad4(len(glyf))-len(glyf))...)
+	}
+	offsets[numGlyphs] = len(glyf)
+
+	loca, err := buildLoca(offsets, indexFormat)
+	if err != nil {
+		return nil, nil, err
+	}
+	return glyf, loca, nil
+}
+
+// compositeGlyph reads the components of a composite glyph, and whether instructions follow
+func compositeGlyph(r *reader) ([]byte, bool) {
+	start := r.off
+	haveInstructions := false
+	for r.err == nil {
+		flags := r.u16()
+		r.u16() // glyph index
+		size := 2
+		if flags&flagArgsAreWords != 0 {
+			size = 4
+		}
+		switch {
+		case flags&flagHaveScale != 0:
+			size += 2
+		case flags&flagHaveXYScale != 0:
+			size += 4
+		case flags&flagHaveTwoByTwo != 0:
+			size += 8
+		}
+		r.bytes(size)
+		haveInstructions = haveInstructions || flags&flagHaveInstructions != 0
+		if flags&flagMoreComponents == 0 {
+			break
+		}
+	}
+	if r.err != nil {
+		return nil, false
+	}
+	return r.b[start:r.off], haveInstructions
+}
+
+// decodeTriplets reads n points of a simple glyph from the flag and glyph streams
+func decodeTriplets(flags, glyphs *reader, n int) []point {
+	points := make([]point, 0, n)
+	x, y := 0, 0
+	for i := 0; i < n && flags.err == nil && glyphs.err == nil; i++ {
+		f := int(flags.u8())
+		onCurve := f&0x80 == 0
+		f &= 0x7F
+
+		var dx, dy int
+		switch {
+		case f < 10:
+			dy = withSign(f, (f&14)<<7+int(glyphs.u8()))
+		case f < 20:
+			dx = withSign(f, ((f-10)&14)<<7+int(glyphs.u8()))
+		case f < 84:
+			b0, b1 := f-20, int(glyphs.u8())
+			dx = withSign(f, 1+(b0&0x30)+b1>>4)
+			dy = withSign(f>>1, 1+(b0&0x0C)<<2+b1&0x0F)
+		case f < 120:
+			b0 := f - 84
+			dx = withSign(f, 1+(b0/12)<<8+int(glyphs.u8()))
+			dy = withSign(f>>1, 1+((b0%12)>>2)<<8+int(glyphs.u8()))
+		case f < 124:
+			b := glyphs.bytes(3)
+			if b == nil {
+				break
+			}
+			dx = withSign(f, int(b[0])<<4+int(b[1])>>4)
+			dy = withSign(f>>1, int(b[1]&0x0F)<<8+int(b[2]))
+		default:
+			b := glyphs.bytes(4)
+			if b == nil {
+				break
+			}
+			dx = withSign(f, int(b[0])<<8+int(b[1]))
+			dy = withSign(f>>1, int(b[2])<<8+int(b[3]))
+		}
+		x, y = x+dx, y+dy
+		points = append(points, point{x: x, y: y, onCurve: onCurve})
+	}
+	return points
+}
+
+// withSign returns v, negated if the lowest bit of flag is not set
+func withSign(flag, v int) int {
+	if flag&1 != 0 {
+		return v
+	}
+	return -v
+}
+
+// pointsBBox returns the bounding box of points, as xMin, yMin, xMax and yMax
+func pointsBBox(points []point) []byte {
+	if len(points) == 0 {
+		return make([]byte, 8)
+	}
+	xMin, yMin, xMax, yMax := points[0].x, points[0].y, points[0].x, points[0].y
+	for _, p := range points[1:] {
+		if p.x < xMin {
+			xMin = p.x
+		}
+		if p.x > xMax {
+			xMax = p.x
+		}
+		if p.y < yMin {
+			yMin = p.y
+		}
+		if p.y > yMax {
+			yMax = p.y
+		}
+	}
+	return be16s(uint16(xMin), uint16(yMin), uint16(xMax), uint16(yMax))
+}
+
+// simpleGlyph encodes a simple glyph in the glyf format
+func simpleGlyph(endPts []uint16, bbox, instructions []byte, points []point, overlap bool) []byte {
+	glyph := be16s(uint16(len(endPts)))
+	glyph = append(glyph, bbox...)
+	glyph = append(glyph, be16s(endPts...)...)
+	glyph = append(glyph, be16s(uint16(len(instructions)))...)
+	glyph = append(glyph, instructions...)
+
+	pointFlags := make([]byte, len(points))
+	xs, ys := []byte{}, []byte{}
+	lastX, lastY := 0, 0
+	for i, p := range points {
+		var f byte
+		if p.onCurve {
+			f |= flagOnCurve
+		}
+		if i == 0 && overlap {
+			f |= flagOverlapSimple
+		}
+
+		dx, dy := p.x-lastX, p.y-lastY
+		lastX, lastY = p.x, p.y
+		switch {
+		case dx == 0:
+			f |= flagXSame
+		case dx > -256 && dx < 256:
+			f |= flagXShort
+			if dx > 0 {
+				f |= flagXSame
+			} else {
+				dx = -dx
+			}
+			xs = append(xs, byte(dx))
+		default:
+			xs = append(xs, be16s(uint16(dx))...)
+		}
+		switch {
+		case dy == 0:
+			f |= flagYSame
+		case dy > -256 && dy < 256:
+			f |= flagYShort
+			if dy > 0 {
+				f |= flagYSame
+			} else {
+				dy = -dy
+			}
+			ys = append(ys, byte(dy))
+		default:
+			ys = append(ys, be16s(uint16(dy))...)
+		}
+		pointFlags[i] = f
+	}
+
+	// runs of the same flag are written once with a repeat count
+	for i := 0; i < len(pointFlags); {
+		run := 1
+		for i+run < len(pointFlags) && pointFlags[i+run] == pointFlags[i] && run < 256 {
+			run++
+		}
+		if run > 1 {
+			glyph = append(glyph, pointFlags[i]|flagRepeat, byte(run-1))
+		} else {
+			glyph = append(glyph, pointFlags[i])
+		}
+		i += run
+	}
+	glyph = append(glyph, xs...)
+	return append(glyph, ys...)
+}
+
+// buildLoca encodes glyph offsets in the loca format: offsets divided by 2 as uint16 for format 0, or uint32
+func buildLoca(offsets []int, indexFormat uint16) ([]byte, error) {
+	switch indexFormat {
+	case 0:
+		loca := make([]byte, 2*len(offsets))
+		for i, v := range offsets {
+			if v/2 > 0xFFFF {
+				return nil, fmt.Errorf("%w: glyf too large for short loca offsets", ErrFormat)
+			}
+			binary.BigEndian.PutUint16(loca[2*i:], uint16(v/2))
+		}
+		return loca, nil
+	case 1:
+		loca := make([]byte, 4*len(offsets))
+		for i, v := range offsets {
+			binary.BigEndian.PutUint32(loca[4*i:], uint32(v))
+		}
+		return loca, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown loca format %d", ErrFormat, indexFormat)
+	}
+}
+
+// reconstructHmtx reverses the woff2 hmtx transformation, which drops left side bearings equal to the xMin of glyphs
+func reconstructHmtx(tables map[string][]byte) ([]byte, error) {
+	f, err := Parse(Build(TrueType, map[string][]byte{
+		"head": tables["head"], "hhea": tables["hhea"], "maxp": tables["maxp"], "glyf": tables["glyf"],
+		"loca": tables["loca"],
+	}))
+	if err != nil {
+		return nil, err
+	}
+	xMins, err := f.glyphXMins()
+	if err != nil {
+		return nil, err
+	}
+	hhea, err := f.Hhea()
+	if err != nil {
+		return nil, err
+	}
+	numHMetrics, numGlyphs := int(hhea.NumberOfHMetrics), len(xMins)
+	if numHMetrics < 1 || numHMetrics > numGlyphs {
+		return nil, fmt.Errorf("%w: bad number of horizontal metrics %d", ErrFormat, numHMetrics)
+	}
+
+	r := &reader{b: tables["hmtx"]}
+	flags := r.u8()
+	advances := make([]uint16, numHMetrics)
+	for i := range advances {
+		advances[i] = r.u16()
+	}
+	lsbs := make([]uint16, numGlyphs)
+	for i := range lsbs {
+		proportional := i < numHMetrics
+		if (proportional && flags&1 != 0) || (!proportional && flags&2 != 0) {
+			lsbs[i] = uint16(xMins[i])
+		} else {
+			lsbs[i] = r.u16()
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: transformed hmtx: %v", ErrFormat, r.err)
+	}
+
+	hmtx := make([]byte, 0, 4*numHMetrics+2*(numGlyphs-numHMetrics))
+	for i := 0; i < numGlyphs; i++ {
+		if i < numHMetrics {
+			hmtx = append(hmtx, be16s(advances[i])...)
+		}
+		hmtx = append(hmtx, be16s(lsbs[i])...)
+	}
+	return hmtx, nil
+}
+
+// glyphXMins returns the xMin of each glyph of the glyf table, 0 for empty glyphs
+func (f *Font) glyphXMins() ([]int16, error) {
+	offsets, err := f.locaOffsets()
+	if err != nil {
+		return nil, err
+	}
+	glyf, err := f.Table("glyf")
+	if err != nil {
+		return nil, err
+	}
+
+	xMins := make([]int16, len(offsets)-1)
+	for i := range xMins {
+		start, end := offsets[i], offsets[i+1]
+		if start == end {
+			continue
+		}
+		if start > end || end > len(glyf) || end-start < 10 {
+			return nil, fmt.Errorf("%w: glyph %d out of bounds", ErrFormat, i)
+		}
+		xMins[i] = i16(glyf, start+2)
+	}
+	return xMins, nil
+}
+
+// locaOffsets returns the offsets of the glyphs in the glyf table, one more than the number of glyphs
+func (f *Font) locaOffsets() ([]int, error) {
+	head, err := f.Head()
+	if err != nil {
+		return nil, err
+	}
+	numGlyphs, err := f.NumGlyphs()
+	if err != nil {
+		return nil, err
+	}
+	loca, err := f.Table("loca")
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make([]int, numGlyphs+1)
+	switch head.IndexToLocFormat {
+	case 0:
+		if len(loca) < 2*len(offsets) {
+			return nil, fmt.Errorf("%w: loca too short", ErrFormat)
+		}
+		for i := range offsets {
+			offsets[i] = 2 * int(u16(loca, 2*i))
+		}
+	case 1:
+		if len(loca) < 4*len(offsets) {
+			return nil, fmt.Errorf("%w: loca too short", ErrFormat)
+		}
+		for i := range offsets {
+			offsets[i] = int(u32(loca, 4*i))
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown loca format %d", ErrFormat, head.IndexToLocFormat)
+	}
+	return offsets, nil
+}
+
+// be16s encodes values as big endian uint16
+func be16s(values ...uint16) []byte {
+	b := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(b[2*i:], v)
+	}
+	return b
+}
+
+func newBrotliReader(data []byte) io.Reader {
+	return brotli.NewReader(bytes.NewReader(data))
+}
+
+// reader reads big endian values from a buffer. Reading past the end sets err and returns zeros.
+type reader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.off+n > len(r.b) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.b[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *reader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+// base128 reads a UIntBase128: 7 bits per byte, most significant first, at most 5 bytes
+func (r *reader) base128() uint32 {
+	var v uint32
+	for i := 0; i < 5; i++ {
+		b := r.u8()
+		if r.err != nil {
+			return 0
+		}
+		if i == 0 && b == 0x80 {
+			r.err = fmt.Errorf("UIntBase128 with leading zeros")
+			return 0
+		}
+		if v&0xFE000000 != 0 {
+			r.err = fmt.Errorf("UIntBase128 overflow")
+			return 0
+		}
+		v = v<<7 | uint32(b&0x7F)
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+	r.err = fmt.Errorf("UIntBase128 longer than 5 bytes")
+	return 0
+}
+
+// uint255 reads a 255UInt16: one byte below 253, or a code byte followed by one or two bytes
+func (r *reader) uint255() uint16 {
+	switch code := r.u8(); code {
+	case 253:
+		return r.u16()
+	case 254:
+		return uint16(r.u8()) + 253*2
+	case 255:
+		return uint16(r.u8()) + 253
+	default:
+		return uint16(code)
+	}
+}
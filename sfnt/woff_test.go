@@ -0,0 +1,317 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// woffFile encodes the tables of font as a WOFF file, compressing tables when it makes them smaller
+func woffFile(t testing.TB, font []byte, meta, priv []byte) []byte {
+	t.Helper()
+	f, err := Parse(font)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := []byte{}
+	body := []byte{}
+	offset := 44 + 20*len(f.Tables)
+	for _, tr := range f.Tables {
+		data, _ := f.Table(tr.Tag)
+		stored := zlibCompress(data)
+		if len(stored) >= len(data) {
+			stored = data
+		}
+		dir = append(dir, be(tr.Tag, uint32(offset+len(body)), uint32(len(stored)), uint32(len(data)), tr.Checksum)...)
+		body = append(body, stored...)
+		body = append(body, make([]byte, pad4(len(body))-len(body))...)
+	}
+
+	var metaOffset, metaLength, privOffset int
+	if meta != nil {
+		compressed := zlibCompress(meta)
+		metaOffset, metaLength = offset+len(body), len(compressed)
+		body = append(body, compressed...)
+	}
+	if priv != nil {
+		body = append(body, make([]byte, pad4(len(body))-len(body))...)
+		privOffset = offset + len(body)
+		body = append(body, priv...)
+	}
+
+	header := be(uint32(woffSignature), f.Version, uint32(offset+len(body)), uint16(len(f.Tables)), uint16(0),
+		uint32(len(font)), uint16(1), uint16(2), uint32(metaOffset), uint32(metaLength), uint32(len(meta)),
+		uint32(privOffset), uint32(len(priv)))
+	return append(append(header, dir...), body...)
+}
+
+func brotliCompress(data []byte) []byte {
+	var buf bytes.Buffer
+	bw := brotli.NewWriter(&buf)
+	bw.Write(data)
+	bw.Close()
+	return buf.Bytes()
+}
+
+func TestDecodeWOFF(t *testing.T) {
+	font := testFont()
+	meta := []byte(`<?xml version="1.0" encoding="UTF-8"?><metadata version="1.0"><uniqueid id="test"/></metadata>`)
+	data := woffFile(t, font, meta, []byte("private"))
+
+	w, err := ParseWOFF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Format != "woff" || w.Flavor != TrueType || w.MajorVersion != 1 || w.MinorVersion != 2 || len(w.Tables) != 8 {
+		t.Errorf("bad header %+v", w)
+	}
+	if got, err := w.Metadata(); err != nil || !bytes.Equal(got, meta) {
+		t.Errorf("got metadata %q, %v", got, err)
+	}
+	if got := w.Private(); string(got) != "private" {
+		t.Errorf("got private data %q", got)
+	}
+
+	decoded, err := Decode(data, &DecodeOptions{VerifyChecksums: true, VerifyBlocks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, font) {
+		t.Errorf("decoded font differs from the original")
+	}
+
+	// a table that does not match its checksum
+	bad := woffFile(t, font, nil, nil)
+	bad[44+20*3+16]++
+	if _, err := Decode(bad, nil); err != nil {
+		t.Errorf("checksums should not be verified by default: %v", err)
+	}
+	if _, err := Decode(bad, &DecodeOptions{VerifyChecksums: true}); !errors.Is(err, ErrChecksum) {
+		t.Errorf("got %v, want ErrChecksum", err)
+	}
+
+	// trailing garbage
+	bad = append(woffFile(t, font, nil, nil), make([]byte, 8)...)
+	if _, err := Decode(bad, &DecodeOptions{VerifyBlocks: true}); !errors.Is(err, ErrFormat) {
+		t.Errorf("got %v, want ErrFormat", err)
+	}
+
+	// metadata that is not XML
+	bad = woffFile(t, font, []byte("<metadata>"), nil)
+	if _, err := Decode(bad, nil); err != nil {
+		t.Errorf("blocks should not be verified by default: %v", err)
+	}
+	if _, err := Decode(bad, &DecodeOptions{VerifyBlocks: true}); !errors.Is(err, ErrFormat) {
+		t.Errorf("got %v, want ErrFormat", err)
+	}
+}
+
+// transformedGlyf is a woff2 transformed glyf table of 3 glyphs: an empty glyph, a triangle with instructions, and a
+// composite of the triangle moved by (5, 5)
+var transformedGlyf = be(uint16(0), uint16(0), uint16(3), uint16(0),
+	uint32(6), uint32(1), uint32(3), uint32(5), uint32(6), uint32(12), uint32(2),
+	// nContours
+	int16(0), int16(1), int16(-1),
+	// nPoints
+	uint8(3),
+	// flags: (+10, 0), (+100, 0), (-50, +300)
+	uint8(11), uint8(11), uint8(90),
+	// glyphs: coordinates, then the instruction length of the triangle
+	uint8(10), uint8(100), uint8(49), uint8(43), uint8(2),
+	// composites
+	uint16(0x0002), uint16(1), uint8(5), uint8(5),
+	// bboxes: the bitmap, then the box of the composite
+	uint32(0x20000000), int16(15), int16(5), int16(115), int16(305),
+	// instructions
+	uint8(1), uint8(2))
+
+// wantGlyf is transformedGlyf in the glyf format, each glyph padded to 4 bytes
+var wantGlyf = be(
+	int16(1), int16(10), int16(0), int16(110), int16(300), uint16(2), uint16(2), uint8(1), uint8(2),
+	uint8(0x33|flagRepeat), uint8(1), uint8(flagOnCurve|flagXShort), uint8(10), uint8(100), uint8(50), int16(300),
+	int16(-1), int16(15), int16(5), int16(115), int16(305), uint16(0x0002), uint16(1), uint8(5), uint8(5))
+
+// woff2File is a WOFF2 file with transformed glyf, loca and hmtx tables, and a table with an unknown tag
+func woff2File(t testing.TB) []byte {
+	t.Helper()
+	f, err := Parse(testFont())
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, _ := f.Table("head")
+	hhea := append([]byte{}, mustTable(t, f, "hhea")...)
+	// 2 horizontal metrics for 3 glyphs
+	hhea[35] = 2
+	maxp := be(uint32(0x00005000), uint16(3))
+	// advances, and no left side bearings since they are all the xMin of the glyphs
+	transformedHmtx := be(uint8(3), uint16(500), uint16(600))
+
+	entries := []struct {
+		flags       byte
+		tag         string
+		data        []byte
+		origLength  uint32
+		transformed bool
+	}{
+		{1, "", head, uint32(len(head)), false},
+		{2, "", hhea, uint32(len(hhea)), false},
+		{4, "", maxp, uint32(len(maxp)), false},
+		{10, "", transformedGlyf, uint32(len(wantGlyf)), true},
+		{11, "", nil, 8, true},
+		{3 | 1<<6, "", transformedHmtx, 10, true},
+		{0x3F, "Test", []byte("data"), 4, false},
+	}
+	dir, stream := []byte{}, []byte{}
+	totalSfntSize := 12 + 16*len(entries)
+	for _, e := range entries {
+		totalSfntSize += pad4(int(e.origLength))
+		dir = append(dir, e.flags)
+		dir = append(dir, e.tag...)
+		dir = append(dir, base128(e.origLength)...)
+		if e.transformed {
+			dir = append(dir, base128(uint32(len(e.data)))...)
+		}
+		stream = append(stream, e.data...)
+	}
+	compressed := brotliCompress(stream)
+	length := 48 + len(dir) + len(compressed)
+	data := be(uint32(woff2Signature), TrueType, uint32(length), uint16(len(entries)), uint16(0), uint32(totalSfntSize),
+		uint32(len(compressed)), uint16(1), uint16(0), make([]byte, 20))
+	return append(append(data, dir...), compressed...)
+}
+
+func TestDecodeWOFF2(t *testing.T) {
+	data := woff2File(t)
+	w, err := ParseWOFF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Format != "woff2" || len(w.Tables) != 7 || w.Tables[6].Tag != "Test" || !w.Tables[3].Transformed {
+		t.Errorf("bad header %+v", w)
+	}
+
+	decoded, err := Decode(data, &DecodeOptions{VerifyChecksums: true, VerifyBlocks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Parse(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.VerifyChecksums(); err != nil {
+		t.Error(err)
+	}
+
+	tests := map[string][]byte{
+		"glyf": wantGlyf,
+		"loca": be(uint16(0), uint16(0), uint16(12), uint16(20)),
+		"hmtx": be(uint16(500), int16(0), uint16(600), int16(10), int16(15)),
+		"Test": []byte("data"),
+	}
+	for tag, want := range tests {
+		if got := mustTable(t, out, tag); !bytes.Equal(got, want) {
+			t.Errorf("%s:\ngot  %x\nwant %x", tag, got, want)
+		}
+	}
+
+	// a truncated glyph stream
+	bad := append([]byte{}, transformedGlyf...)
+	bad[8+4*3+3] = 4
+	if _, _, err := reconstructGlyf(bad); !errors.Is(err, ErrFormat) {
+		t.Errorf("got %v, want ErrFormat", err)
+	}
+}
+
+func TestWOFF2Numbers(t *testing.T) {
+	for _, v := range []uint32{0, 63, 127, 128, 16383, 1 << 20, 1<<32 - 1} {
+		r := &reader{b: base128(v)}
+		if got := r.base128(); r.err != nil || got != v {
+			t.Errorf("base128 %d: got %d, %v", v, got, r.err)
+		}
+	}
+	for _, b := range [][]byte{{0x80, 0x01}, {0x90, 0x80, 0x80, 0x80, 0x00}, {0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F}} {
+		r := &reader{b: b}
+		if r.base128(); r.err == nil {
+			t.Errorf("%x: expect error", b)
+		}
+	}
+
+	tests := map[uint16][]byte{
+		252: {252},
+		253: {255, 0},
+		505: {255, 252},
+		506: {254, 0},
+		761: {254, 255},
+		762: {253, 0x02, 0xFA},
+	}
+	for want, b := range tests {
+		r := &reader{b: b}
+		if got := r.uint255(); r.err != nil || got != want {
+			t.Errorf("255UInt16 %x: got %d, want %d", b, got, want)
+		}
+	}
+}
+
+// failingReader fails the test if it is read
+type failingReader struct{ t *testing.T }
+
+func (r failingReader) Read([]byte) (int, error) {
+	r.t.Fatal("unexpected read")
+	return 0, nil
+}
+
+func TestWOFFDecodedSize(t *testing.T) {
+	files := map[string][]byte{"woff": woffFile(t, testFont(), nil, nil), "woff2": woff2File(t)}
+	for format, data := range files {
+		if _, err := ParseWOFF(data); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		for _, size := range []uint32{1 << 30, 100} {
+			bad := append([]byte{}, data...)
+			binary.BigEndian.PutUint32(bad[16:], size)
+			if _, err := Decode(bad, nil); !errors.Is(err, ErrFormat) {
+				t.Errorf("%s with totalSfntSize %d: got %v, want ErrFormat", format, size, err)
+			}
+		}
+	}
+
+	if _, err := readAllN(failingReader{t}, maxSfntSize+1, "test"); !errors.Is(err, ErrFormat) {
+		t.Errorf("got %v, want ErrFormat", err)
+	}
+}
+
+func mustTable(t testing.TB, f *Font, tag string) []byte {
+	t.Helper()
+	b, err := f.Table(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(testFont())
+	f.Add(woffFile(f, testFont(), []byte(`<metadata version="1.0"/>`), []byte("private")))
+	f.Add(woff2File(f))
+	if data, err := EncodeWOFF2(testFont()); err == nil {
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, opts := range []*DecodeOptions{nil, {VerifyChecksums: true, VerifyBlocks: true}} {
+			decoded, err := Decode(data, opts)
+			if err != nil {
+				continue
+			}
+			font, err := Parse(decoded)
+			if err != nil {
+				t.Fatalf("cannot parse decoded font: %v", err)
+			}
+			font.Info()
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package gfont
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/imacks/gfont/sfnt"
+)
+
+// SplitOptions controls how SplitFont writes the subset files
+type SplitOptions struct {
+	// Format is the format of the files: woff2, woff or truetype. It defaults to woff2.
+	Format string
+	// Name is the start of the file names, followed by the subset label and the extension of the format, as in
+	// Domine-Regular.latin-ext.woff2. It defaults to the PostScript name of the font. Characters other than ASCII
+	// letters, digits, - and _ are replaced by -, in the name and the label.
+	Name string
+	// BaseURL is prepended to the file names in the URLs of the fonts. If empty, the URLs are the file names.
+	BaseURL string
+	// DropLayout removes the OpenType layout tables from the files, see sfnt.SubsetOptions
+	DropLayout bool
+}
+
+// SplitFile is a font file written by SplitFont
+type SplitFile struct {
+	Name string
+	Data []byte
+}
+
+// SplitFont splits font, a TTF, WOFF or WOFF2 file, into one file for each unicode range of subsets, the way css2
+// serves a family in several files. subsets is usually parsed from the css2 CSS of the family: each distinct subset
+// label and unicode range makes a file, and the ranges the font has no character of are skipped. face is copied into
+// the @font-face of each file, with the URL, format, subset and unicode range set. If face.Family is empty, the family,
+// weight and style are read from the font.
+func SplitFont(font []byte, face Typeface, subsets *Typefaces, opts *SplitOptions) (*Typefaces, []SplitFile, error) {
+	if opts == nil {
+		opts = &SplitOptions{}
+	}
+	format := opts.Format
+	if format == "" {
+		format = "woff2"
+	}
+	if format != "woff2" && format != "woff" && format != "truetype" {
+		return nil, nil, fmt.Errorf("cannot split into %s files", format)
+	}
+
+	decoded, err := sfnt.Decode(font, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	parsed, err := sfnt.Parse(decoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := parsed.Info()
+	if err != nil {
+		return nil, nil, err
+	}
+	if face.Family == "" {
+		face = faceOf(info, face)
+	}
+	name := opts.Name
+	if name == "" {
+		name = info.PostScriptName
+	}
+	if name == "" {
+		name = "font"
+	}
+	name = pathSlug(name, true)
+
+	fontRunes := make([]RuneRange, len(info.Runes))
+	for i, r := range info.Runes {
+		fontRunes[i] = RuneRange{First: r, Last: r}
+	}
+	fontRange := NewUnicodeRange(fontRunes...)
+
+	result := &Typefaces{Fonts: []Typeface{}}
+	files := []SplitFile{}
+	ranges, labels := map[string]bool{}, map[string]bool{}
+	for i, t := range subsets.Fonts {
+		if len(t.UnicodeRange) == 0 || ranges[t.UnicodeRange.String()] {
+			continue
+		}
+		ranges[t.UnicodeRange.String()] = true
+		runes := fontRange.Intersect(t.UnicodeRange)
+		if runes.Len() == 0 {
+			continue
+		}
+
+		// numbered subsets are labeled as in [0]
+		label := pathSlug(strings.Trim(t.Subset, "[]"), true)
+		if label == "" {
+			label = strconv.Itoa(i)
+		}
+		for base, n := label, i; labels[label]; n++ {
+			label = base + "-" + strconv.Itoa(n)
+		}
+		labels[label] = true
+
+		data, err := sfnt.Subset(decoded, runes.Contains, &sfnt.SubsetOptions{DropLayout: opts.DropLayout})
+		if err != nil {
+			return nil, nil, err
+		}
+		switch format {
+		case "woff2":
+			data, err = sfnt.EncodeWOFF2(data)
+		case "woff":
+			data, err = sfnt.EncodeWOFF(data)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		file := SplitFile{Name: name + "." + label + "." + fontExtensions[format], Data: data}
+		u, err := localURL(opts.BaseURL, file.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		tf := face
+		tf.Format = format
+		tf.URL = u
+		tf.Sources = nil
+		tf.Subset = t.Subset
+		tf.TextSubset = false
+		tf.UnicodeRange = t.UnicodeRange
+		result.Fonts = append(result.Fonts, tf)
+		files = append(files, file)
+	}
+	return result, files, nil
+}
+
+// faceOf fills in the family, weight and style of face from the font
+func faceOf(info *sfnt.Info, face Typeface) Typeface {
+	face.Family = info.TypographicFamily
+	if face.Family == "" {
+		face.Family = info.Family
+	}
+	if face.Weight == (AxisValue{}) {
+		face.Weight = AxisPoint(float64(info.WeightClass))
+		for _, axis := range info.Axes {
+			if axis.Tag == AxisWeight {
+				face.Weight = AxisRange(axis.Min, axis.Max)
+			}
+		}
+	}
+	if face.Style == "" {
+		face.Style = "normal"
+		if info.Italic {
+			face.Style = "italic"
+		}
+	}
+	return face
+}
@@ -0,0 +1,105 @@
+package gfont
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/imacks/gfont/sfnt"
+)
+
+// splitFont is a font file with the cmap of cmapTable, 5 empty glyphs and postScriptName in its name table
+func splitFont(postScriptName string) []byte {
+	head := make([]byte, 54)
+	binary.BigEndian.PutUint32(head[0:], 0x00010000)
+	binary.BigEndian.PutUint32(head[12:], 0x5F0F3CF5)
+	binary.BigEndian.PutUint16(head[18:], 1000)
+	hhea := make([]byte, 36)
+	binary.BigEndian.PutUint16(hhea[34:], 5)
+	maxp := make([]byte, 6)
+	binary.BigEndian.PutUint32(maxp[0:], 0x00005000)
+	binary.BigEndian.PutUint16(maxp[4:], 5)
+
+	psName := utf16.Encode([]rune(postScriptName))
+	name := make([]byte, 18+2*len(psName))
+	binary.BigEndian.PutUint16(name[2:], 1)
+	binary.BigEndian.PutUint16(name[4:], 18)
+	binary.BigEndian.PutUint16(name[6:], 3)
+	binary.BigEndian.PutUint16(name[8:], 1)
+	binary.BigEndian.PutUint16(name[10:], 0x409)
+	binary.BigEndian.PutUint16(name[12:], sfnt.NamePostScript)
+	binary.BigEndian.PutUint16(name[14:], uint16(2*len(psName)))
+	for i, c := range psName {
+		binary.BigEndian.PutUint16(name[18+2*i:], c)
+	}
+
+	return sfnt.Build(sfnt.TrueType, map[string][]byte{
+		"cmap": cmapTable(), "head": head, "hhea": hhea, "maxp": maxp, "name": name,
+		"glyf": {}, "loca": make([]byte, 2*6), "hmtx": make([]byte, 4*5),
+	})
+}
+
+func TestSplitFont(t *testing.T) {
+	subsets := &Typefaces{Fonts: []Typeface{
+		{UnicodeRange: mustParseUnicodeRange(t, "U+0041")},
+		// labeled as the unlabeled subset before it
+		{Subset: "[0]", UnicodeRange: mustParseUnicodeRange(t, "U+00E0-00FF")},
+		// the same range as the first subset
+		{Subset: "latin", UnicodeRange: mustParseUnicodeRange(t, "U+0041")},
+		// no character of the font
+		{Subset: "cyrillic", UnicodeRange: mustParseUnicodeRange(t, "U+0400-04FF")},
+		{Subset: "latin", UnicodeRange: mustParseUnicodeRange(t, "U+0042-0043")},
+		{Subset: "0-1", UnicodeRange: mustParseUnicodeRange(t, "U+0043")},
+	}}
+	face := Typeface{Family: "Domine", Style: "normal", Weight: AxisPoint(400)}
+
+	ts, files, err := SplitFont(splitFont("../Domine Regular"), face, subsets, &SplitOptions{BaseURL: "/fonts/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantNames := []string{"---Domine-Regular.0.woff2", "---Domine-Regular.0-1.woff2", "---Domine-Regular.latin.woff2",
+		"---Domine-Regular.0-1-5.woff2"}
+	wantSubsets := []string{"", "[0]", "latin", "0-1"}
+	if len(files) != len(wantNames) || len(ts.Fonts) != len(wantNames) {
+		t.Fatalf("got %d files and %d fonts, want %d", len(files), len(ts.Fonts), len(wantNames))
+	}
+	for i, f := range files {
+		tf := ts.Fonts[i]
+		if f.Name != wantNames[i] || tf.URL.String() != "/fonts/"+wantNames[i] {
+			t.Errorf("font %d: got file %s, URL %s, want %s", i, f.Name, tf.URL, wantNames[i])
+		}
+		if tf.Format != "woff2" || tf.Subset != wantSubsets[i] || tf.Family != "Domine" || len(tf.Sources) != 0 {
+			t.Errorf("font %d: got %+v", i, tf)
+		}
+		if !strings.HasPrefix(string(f.Data), "wOF2") {
+			t.Errorf("font %d: not a WOFF2 file", i)
+		}
+	}
+	if got := ts.Fonts[1].UnicodeRange.String(); got != "U+00E0-00FF" {
+		t.Errorf("got unicode range %s", got)
+	}
+
+	ts, files, err = SplitFont(splitFont(""), face, subsets, &SplitOptions{Format: "truetype", Name: "Domine/Bold"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files[2].Name != "Domine-Bold.latin.ttf" || ts.Fonts[2].URL.String() != "Domine-Bold.latin.ttf" || ts.Fonts[2].Format != "truetype" {
+		t.Errorf("got file %s, %s %s", files[2].Name, ts.Fonts[2].Format, ts.Fonts[2].URL)
+	}
+	font, err := sfnt.Parse(files[2].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmap, err := font.Cmap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cmap.Lookup('A'); ok || cmap.Len() != 2 {
+		t.Errorf("got %d characters in the latin file", cmap.Len())
+	}
+
+	if _, _, err := SplitFont(splitFont(""), face, subsets, &SplitOptions{Format: "svg"}); err == nil {
+		t.Errorf("expect error for svg")
+	}
+}
@@ -2,8 +2,10 @@ package gfont
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"strconv"
+	"unicode"
 	"net/url"
 	"encoding/json"
 
@@ -13,11 +15,46 @@ import (
 // Typeface represents a font face
 type Typeface struct {
 	Format string          `json:"format"`
-	Weight int             `json:"weight"`
+	// Weight is a single weight, or a range of weights for a variable font. It is zero if not set.
+	Weight AxisValue       `json:"weight"`
 	Family string          `json:"family"`
+	// Style is normal, italic or oblique
 	Style string           `json:"style"`
 	URL *url.URL           `json:"url"`
-	UnicodeRange []string  `json:"unicodeRange,omitempty"`
+	// UnicodeRange is the set of characters the font is used for. It is empty if the font is used for all characters.
+	UnicodeRange UnicodeRange `json:"unicodeRange,omitempty"`
+	Profile string         `json:"profile,omitempty"`
+	// Subset is the character subset the font covers, such as latin-ext, as labeled by css2
+	Subset string          `json:"subset,omitempty"`
+	// TextSubset is true for fonts reduced to the characters of the text= parameter
+	TextSubset bool        `json:"textSubset,omitempty"`
+	Sources []Source       `json:"sources,omitempty"`
+	// Slant is the angle, or range of angles, of an oblique font in degrees. A positive angle leans to the right, like
+	// italics. It is nil for other styles, and for oblique fonts using the default angle.
+	Slant *AxisValue       `json:"slant,omitempty"`
+	// Stretch is a width, or a range of widths, in percent
+	Stretch *AxisValue     `json:"stretch,omitempty"`
+	Display string         `json:"display,omitempty"`
+	// SizeAdjust and the metric overrides are percentages. They are nil if not set, or set to normal.
+	SizeAdjust *float64                `json:"sizeAdjust,omitempty"`
+	AscentOverride *float64            `json:"ascentOverride,omitempty"`
+	DescentOverride *float64           `json:"descentOverride,omitempty"`
+	LineGapOverride *float64           `json:"lineGapOverride,omitempty"`
+	FeatureSettings []FeatureSetting   `json:"featureSettings,omitempty"`
+	// Descriptors holds any other descriptor by name, with its value as written in the CSS
+	Descriptors map[string]string      `json:"descriptors,omitempty"`
+}
+
+// Source is an entry of the src descriptor. It is either a font file URL or the name of a locally installed font.
+type Source struct {
+	// URL is the font file. It is nil for local fonts.
+	URL *url.URL
+	// Local is the name of a locally installed font
+	Local string
+	// Format is the format hint, such as woff2. EOT files referenced with the ?#iefix trick have format eot.
+	Format string
+	// Tech is the comma separated list of technology hints, such as variations
+	Tech string
 }
 
 // Typefaces represents a collection of Typeface
@@ -32,13 +69,23 @@ func UnmarshalCSS(cssBytes []byte, typefaces *Typefaces) error {
 	}
 	result := []Typeface{}
 
-	s := scanner.New(string(cssBytes))
+	s := &tokenReader{Scanner: scanner.New(string(cssBytes))}
+	subset := ""
 	for {
-		ptoken := nextSig(s)
+		// css2 labels each font face with its subset, as in /* latin-ext */
+		ptoken := s.Next()
+		if ptoken.Type == scanner.TokenS {
+			continue
+		}
+		if ptoken.Type == scanner.TokenComment {
+			subset = commentSubset(ptoken.Value)
+			continue
+		}
 		if ptoken.Type == scanner.TokenEOF || ptoken.Type == scanner.TokenError {
 			break
 		}
 		if ptoken.Type != scanner.TokenAtKeyword || ptoken.Value != "@font-face" {
+			subset = ""
 			continue
 		}
  		lastGoodToken := ptoken.String()
@@ -49,7 +96,8 @@ func UnmarshalCSS(cssBytes []byte, typefaces *Typefaces) error {
 		}
 		lastGoodToken = ptoken.String()
 
-		fface := Typeface{}
+		fface := Typeface{Subset: subset}
+		subset = ""
 		for {
 			token := nextSig(s)
 			if token.Type == scanner.TokenEOF || token.Type == scanner.TokenError {
@@ -67,71 +115,98 @@ func UnmarshalCSS(cssBytes []byte, typefaces *Typefaces) error {
 					return fmt.Errorf("expect : after %s", lastGoodToken)
 				}
 
+				// quoted, or a sequence of identifiers
 				lastGoodToken = token.String()
 				token = nextSig(s)
-				if token.Type != scanner.TokenString {
-					return fmt.Errorf("expect <string> after %s", lastGoodToken)
+				if token.Type == scanner.TokenString {
+					fface.Family = unquote(token.Value)
+					lastGoodToken = token.String()
+					token = nextSig(s)
+				} else {
+					names := []string{}
+					for token.Type == scanner.TokenIdent {
+						names = append(names, token.Value)
+						lastGoodToken = token.String()
+						token = nextSig(s)
+					}
+					if len(names) == 0 {
+						return fmt.Errorf("expect <string> or <ident> after %s", lastGoodToken)
+					}
+					fface.Family = strings.Join(names, " ")
 				}
-				fface.Family = strings.Trim(token.Value, "'")
 
-				lastGoodToken = token.String()
-				token = nextSig(s)
-				if token.Type != scanner.TokenChar || token.Value != ";" {
-					return fmt.Errorf("expect ; after %s", lastGoodToken)
+				if err := endDeclaration(s, token, lastGoodToken); err != nil {
+					return err
 				}
 				continue
 			}
 
-			if token.Type == scanner.TokenIdent && token.Value == "font-style" {
+			if token.Type == scanner.TokenIdent && token.Value == "src" {
 				lastGoodToken = token.String()
 				token = nextSig(s)
 				if token.Type != scanner.TokenChar || token.Value != ":" {
 					return fmt.Errorf("expect : after %s", lastGoodToken)
 				}
 
+				// a later src declaration replaces an earlier one, as in CSS
 				lastGoodToken = token.String()
-				token = nextSig(s)
-				if token.Type != scanner.TokenIdent {
-					return fmt.Errorf("expect <ident> after %s", lastGoodToken)
+				sources, errSrc := parseSources(s, lastGoodToken)
+				if errSrc != nil {
+					return errSrc
 				}
-				fface.Style = token.Value
-
-				lastGoodToken = token.String()
-				token = nextSig(s)
-				if token.Type != scanner.TokenChar || token.Value != ";" {
-					return fmt.Errorf("expect ; after %s", lastGoodToken)
+				fface.URL = nil
+				fface.Format = ""
+				for _, src := range sources {
+					if src.URL != nil {
+						fface.URL = src.URL
+						fface.Format = src.Format
+						break
+					}
+				}
+				// a single url is fully described by URL and Format
+				fface.Sources = nil
+				if len(sources) > 1 || sources[0].IsLocal() || sources[0].Tech != "" {
+					fface.Sources = sources
 				}
+				fface.TextSubset = fface.FontURL().Kind == KitURL
 				continue
 			}
 
-			if token.Type == scanner.TokenIdent && token.Value == "font-weight" {
+			if token.Type == scanner.TokenIdent && token.Value == "unicode-range" {
 				lastGoodToken = token.String()
 				token = nextSig(s)
 				if token.Type != scanner.TokenChar || token.Value != ":" {
 					return fmt.Errorf("expect : after %s", lastGoodToken)
 				}
 
-				lastGoodToken = token.String()
-				token = nextSig(s)
-				if token.Type != scanner.TokenNumber {
-					return fmt.Errorf("expect <number> after %s", lastGoodToken)
-				}
-				var errNum error
-				fface.Weight, errNum = strconv.Atoi(token.Value)
-				if errNum != nil {
-					return fmt.Errorf("convert token to number failed after %s: %v", lastGoodToken, errNum)
-				}
+				ranges := []RuneRange{}
+				for {
+					subtoken := nextSig(s)
+					if subtoken.Type != scanner.TokenUnicodeRange {
+						if subtoken.Type == scanner.TokenEOF || subtoken.Type == scanner.TokenError {
+							return fmt.Errorf("unexpected EOF after %s", lastGoodToken)
+						} else if subtoken.Type == scanner.TokenChar && subtoken.Value == "," {
+							continue
+						} else if subtoken.Type == scanner.TokenChar && (subtoken.Value == ";" || subtoken.Value == "}") {
+							_ = endDeclaration(s, subtoken, lastGoodToken)
+							break
+						}
+						return fmt.Errorf("expect <unicode-range> after %s but got %s", lastGoodToken, subtoken.String())
+					}
 
-				lastGoodToken = token.String()
-				token = nextSig(s)
-				if token.Type != scanner.TokenChar || token.Value != ";" {
-					return fmt.Errorf("expect ; after %s", lastGoodToken)
+					r, errRange := parseRuneRange(subtoken.Value)
+					if errRange != nil {
+						return errRange
+					}
+					lastGoodToken = subtoken.String()
+					ranges = append(ranges, r)
 				}
+				fface.UnicodeRange = NewUnicodeRange(ranges...)
 				continue
 			}
 
-			// #todo support multiple urls
-			if token.Type == scanner.TokenIdent && token.Value == "src" {
+			if token.Type == scanner.TokenIdent {
+				name := token.Value
 				lastGoodToken = token.String()
 				token = nextSig(s)
 				if token.Type != scanner.TokenChar || token.Value != ":" {
@@ -139,75 +214,66 @@ func UnmarshalCSS(cssBytes []byte, typefaces *Typefaces) error {
 				}
 
 				lastGoodToken = token.String()
-				token = nextSig(s)
-				if token.Type != scanner.TokenURI {
-					return fmt.Errorf("expect <uri> after %s", lastGoodToken)
-				}			
-				urlString := strings.Trim(strings.TrimSuffix(strings.TrimPrefix(token.Value, "url("), ")"), "'")
-				var errURL error
-				fface.URL, errURL = url.Parse(urlString)
-				if errURL != nil {
-					return fmt.Errorf("parse url failed after %s", lastGoodToken)
+				value, errValue := readValue(s, lastGoodToken)
+				if errValue != nil {
+					return errValue
 				}
-
-				lastGoodToken = token.String()
-				token = nextSig(s)
-				// EOT format
-				//   src: url(https://xxx.eot);
-				if token.Type == scanner.TokenChar && token.Value == ";" {
-					fface.Format = "eot"
-					continue
-				}
-
-				if token.Type != scanner.TokenFunction || token.Value != "format(" {
-					return fmt.Errorf("expect format( after %s but got %s", lastGoodToken, token.String())
+				if len(value) == 0 {
+					return fmt.Errorf("expect value after %s", lastGoodToken)
 				}
 
-				lastGoodToken = token.String()
-				token = nextSig(s)
-				if token.Type != scanner.TokenString {
-					return fmt.Errorf("expect <string> after %s", lastGoodToken)
-				}
-				fface.Format = strings.Trim(token.Value, "'")
-				
-				lastGoodToken = token.String()
-				token = nextSig(s)
-				if token.Type != scanner.TokenChar || token.Value != ")" {
-					return fmt.Errorf("expect ) after %s", lastGoodToken)
-				}
-
-				lastGoodToken = token.String()
-				token = nextSig(s)
-				if token.Type != scanner.TokenChar || token.Value != ";" {
-					return fmt.Errorf("expect ; after %s", lastGoodToken)
-				}
-				continue
-			}
-
-			if token.Type == scanner.TokenIdent && token.Value == "unicode-range" {
-				lastGoodToken = token.String()
-				token = nextSig(s)
-				if token.Type != scanner.TokenChar || token.Value != ":" {
-					return fmt.Errorf("expect : after %s", lastGoodToken)
-				}
-
-				unicodeRange := []string{}
-				for {
-					subtoken := nextSig(s)
-					if subtoken.Type != scanner.TokenUnicodeRange {
-						if subtoken.Type == scanner.TokenEOF || subtoken.Type == scanner.TokenError {
-							return fmt.Errorf("unexpected EOF after %s", lastGoodToken)
-						} else if subtoken.Type == scanner.TokenChar && subtoken.Value == "," {
-							continue
-						} else if subtoken.Type == scanner.TokenChar && subtoken.Value == ";" {
-							break
-						}
+				switch name {
+				case "font-display":
+					if len(value) != 1 || value[0].Type != scanner.TokenIdent || isUniqueString(displayValues, value[0].Value) {
+						return fmt.Errorf("unsupported %s: %s", name, rawValue(value))
 					}
-
-					lastGoodToken = subtoken.String()
-					unicodeRange = append(unicodeRange, subtoken.Value)
+					fface.Display = value[0].Value
+				case "font-style":
+					style, slant, errStyle := parseStyle(value)
+					if errStyle != nil {
+						return fmt.Errorf("%s: %v", name, errStyle)
+					}
+					fface.Style = style
+					fface.Slant = slant
+				case "font-weight":
+					weight, errWeight := parseWeight(value)
+					if errWeight != nil {
+						return fmt.Errorf("%s: %v", name, errWeight)
+					}
+					fface.Weight = weight
+				case "font-stretch":
+					stretch, errStretch := parseStretch(value)
+					if errStretch != nil {
+						return fmt.Errorf("%s: %v", name, errStretch)
+					}
+					fface.Stretch = stretch
+				case "size-adjust", "ascent-override", "descent-override", "line-gap-override":
+					v, errPercent := parsePercentage(value)
+					if errPercent != nil {
+						return fmt.Errorf("%s: %v", name, errPercent)
+					}
+					switch name {
+					case "size-adjust":
+						fface.SizeAdjust = v
+					case "ascent-override":
+						fface.AscentOverride = v
+					case "descent-override":
+						fface.DescentOverride = v
+					default:
+						fface.LineGapOverride = v
+					}
+				case "font-feature-settings":
+					features, errFeatures := parseFeatureSettings(value)
+					if errFeatures != nil {
+						return fmt.Errorf("%s: %v", name, errFeatures)
+					}
+					fface.FeatureSettings = features
+				default:
+					if fface.Descriptors == nil {
+						fface.Descriptors = map[string]string{}
+					}
+					fface.Descriptors[name] = rawValue(value)
 				}
-				fface.UnicodeRange = unicodeRange
 				continue
 			}
 		}
@@ -219,20 +285,25 @@ func UnmarshalCSS(cssBytes []byte, typefaces *Typefaces) error {
 	return nil
 }
 
-// Select returns a slice of Typeface based on the selection criteria
-func (ts *Typefaces) Select(format, family, style string, weight int) []Typeface {
+// Select returns a slice of Typeface based on the selection criteria. A font with a range of weights matches any
+// weight in the range. style is a font-style value: oblique matches any oblique font, and oblique 10deg matches the
+// oblique fonts whose angles include 10 degrees. If subsets are given, only fonts of those subsets are returned.
+func (ts *Typefaces) Select(format, family, style string, weight int, subsets ...string) []Typeface {
 	filtered := []Typeface{}
 	for _, v := range ts.Fonts {
-		if format != "" && v.Format != format {
+		if format != "" && !v.hasFormat(format) {
 			continue
 		}
 		if family != "" && v.Family != family {
 			continue
 		}
-		if style != "" && v.Style != style {
+		if style != "" && !v.matchStyle(style) {
 			continue
 		}
-		if weight != -1 && v.Weight != weight {
+		if weight != -1 && !v.Weight.Contains(float64(weight)) {
+			continue
+		}
+		if len(subsets) > 0 && isUniqueString(subsets, v.Subset) {
 			continue
 		}
 		filtered = append(filtered, v)
@@ -241,156 +312,107 @@ func (ts *Typefaces) Select(format, family, style string, weight int) []Typeface
 	return filtered
 }
 
-// CSS returns the CSS for all fonts in the collection, in the most legacy compatible manner
+// CSS returns the CSS for all fonts in the collection, in the most legacy compatible manner. Fonts with the same
+// family, style, weight and unicode range are combined into one font face, with one source per format. The result
+// can be parsed back with UnmarshalCSS.
 func (ts *Typefaces) CSS() string {
-	cssTpl := `@font-face{font-family:%s;font-style:%s;font-weight:%d;`
-
-	families := ts.Family()
-	weights := ts.Weight()
-	styles := ts.Style()
-	formats := ts.Format()
-
 	result := []string{}
-	for _, fam := range families {
-		fontFamily := fam
-		if strings.Contains(fam, " ") {
-			fontFamily = "'" + fam + "'"
-		}
-
-		for _, w := range weights {
-			for _, s := range styles {
-				cssPart := fmt.Sprintf(cssTpl, fontFamily, s, w)
-
-				selected := map[string][]Typeface{}
-				for _, m := range formats {
-					filtered := ts.Select(m, fam, s, w)
-					if len(filtered) > 0 {
-						selected[m] = filtered
-					}
-				}
-
-				if v, ok := selected["eot"]; ok {
-					cssPart = cssPart + fmt.Sprintf("src:url('%s');", v[0].URL.String())
-					cssPart = cssPart + fmt.Sprintf("src:url('%s') format('embedded-opentype')", v[0].URL.String()+"?#iefix")
-					if len(selected) == 1 {
-						cssPart = cssPart + ";"
-					} else {
-						cssPart = cssPart + ","
-					}
-				} else {
-					cssPart = cssPart + "src:"
-				}
-
-				if v, ok := selected["woff2"]; ok {
-					cssPart = cssPart + fmt.Sprintf("url('%s') format('woff2'),", v[0].URL.String())
-				}
-				if v, ok := selected["woff"]; ok {
-					cssPart = cssPart + fmt.Sprintf("url('%s') format('woff'),", v[0].URL.String())
-				}
-				if v, ok := selected["ttf"]; ok {
-					cssPart = cssPart + fmt.Sprintf("url('%s') format('ttf'),", v[0].URL.String())
-				}
-				if v, ok := selected["svg"]; ok {
-					cssPart = cssPart + fmt.Sprintf("url('%s') format('svg'),", v[0].URL.String())
-				}
-
-				cssPart = strings.TrimSpace(cssPart)
-				cssPart = strings.TrimSuffix(cssPart, ",")
-				cssPart = strings.TrimSuffix(cssPart, ";")
-				cssPart = cssPart + "}"
-
-				result = append(result, cssPart)
-			}
-		}
+	for _, face := range ts.compatFaces(false) {
+		result = append(result, renderFontFace(face, false))
 	}
-
 	return strings.Join(result, "")
 }
 
 // PrettyCSS is the human readable version of method CSS
 func (ts *Typefaces) PrettyCSS() string {
-	cssTpl := `@font-face {
-	font-family: %s;
-	font-style: %s; 
-	font-weight: %d;`
-
-	families := ts.Family()
-	weights := ts.Weight()
-	styles := ts.Style()
-	formats := ts.Format()
-
 	result := []string{}
-	for _, fam := range families {
-		fontFamily := fam
-		if strings.Contains(fam, " ") {
-			fontFamily = "'" + fam + "'"
+	for _, face := range ts.compatFaces(true) {
+		result = append(result, renderFontFace(face, true))
+	}
+	return strings.Join(result, "\n") + "\n"
+}
+
+// compatFaces groups the fonts into font faces and returns the declarations of each
+func (ts *Typefaces) compatFaces(pretty bool) []fontFace {
+	keys := []string{}
+	groups := map[string][]Typeface{}
+	for _, v := range ts.Fonts {
+		key := v.faceKey()
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
 		}
+		groups[key] = append(groups[key], v)
+	}
 
-		for _, w := range weights {
-			for _, s := range styles {
-				cssPart := fmt.Sprintf(cssTpl, fontFamily, s, w)
+	srcSep := ","
+	if pretty {
+		srcSep = ",\n\t\t"
+	}
 
-				selected := map[string][]Typeface{}
-				for _, m := range formats {
-					filtered := ts.Select(m, fam, s, w)
-					if len(filtered) > 0 {
-						selected[m] = filtered
+	result := []fontFace{}
+	for _, key := range keys {
+		group := groups[key]
+
+		locals := []Source{}
+		byFormat := map[string]Source{}
+		formats := []string{}
+		for _, v := range group {
+			for _, src := range v.AllSources() {
+				if src.IsLocal() {
+					if !containsSource(locals, src) {
+						locals = append(locals, src)
 					}
+					continue
 				}
-
-				if v, ok := selected["eot"]; ok {
-					cssPart = cssPart + fmt.Sprintf("\n\tsrc: url('%s');", v[0].URL.String())
-					cssPart = cssPart + fmt.Sprintf("\n\tsrc: url('%s') format('embedded-opentype')", v[0].URL.String()+"?#iefix")
-					if len(selected) == 1 {
-						cssPart = cssPart + ";"
-					} else {
-						cssPart = cssPart + ",\n\t\t"
-					}
-				} else {
-					cssPart = cssPart + "\n\tsrc: "
+				if _, ok := byFormat[src.Format]; ok {
+					continue
 				}
+				byFormat[src.Format] = src
+				formats = append(formats, src.Format)
+			}
+		}
+		sort.SliceStable(formats, func(i, j int) bool {
+			return formatRank(formats[i]) < formatRank(formats[j])
+		})
 
-				if v, ok := selected["woff2"]; ok {
-					cssPart = cssPart + fmt.Sprintf("url('%s') format('woff2'),\n\t\t", v[0].URL.String())
-				}
-				if v, ok := selected["woff"]; ok {
-					cssPart = cssPart + fmt.Sprintf("url('%s') format('woff'),\n\t\t", v[0].URL.String())
-				}
-				if v, ok := selected["ttf"]; ok {
-					cssPart = cssPart + fmt.Sprintf("url('%s') format('ttf'),\n\t\t", v[0].URL.String())
-				}
-				if v, ok := selected["svg"]; ok {
-					cssPart = cssPart + fmt.Sprintf("url('%s') format('svg'),\n\t\t", v[0].URL.String())
-				}
+		sources := append([]Source{}, locals...)
+		for _, m := range formats {
+			sources = append(sources, byFormat[m])
+		}
 
-				cssPart = strings.TrimSpace(cssPart)
-				cssPart = strings.TrimSuffix(cssPart, ",")
-				if !strings.HasSuffix(cssPart, ";") {
-					cssPart = cssPart + ";"
+		face := group[0]
+		face.Sources = sources
+		decls := face.declarations(face.srcCSS(srcSep), pretty)
+		if eot, ok := byFormat["eot"]; ok {
+			// IE9 compat mode only understands a src with a single url and no format
+			legacy := [2]string{"src", fmt.Sprintf("url(%s)", quote(eot.URL.String()))}
+			for i, d := range decls {
+				if d[0] == "src" {
+					decls = append(decls[:i], append([][2]string{legacy}, decls[i:]...)...)
+					break
 				}
-				cssPart = cssPart + "\n}"
-
-				result = append(result, cssPart)
 			}
 		}
+		result = append(result, fontFace{Subset: face.Subset, Decls: decls})
 	}
-
-	return strings.Join(result, "\n") + "\n"
+	return result
 }
 
 // Format returns a unique list of font formats
 func (ts *Typefaces) Format() []string {
 	result := []string{}
 	for _, v := range ts.Fonts {
-		if v.Format == "" {
-			continue
-		}
+		for _, src := range v.AllSources() {
+			if src.Format == "" {
+				continue
+			}
 
-		if !isUniqueString(result, v.Format) {
-			continue
-		}
+			if !isUniqueString(result, src.Format) {
+				continue
+			}
 
-		result = append(result, v.Format)
+			result = append(result, src.Format)
+		}
 	}
 	return result
 }
@@ -412,32 +434,61 @@ func (ts *Typefaces) Family() []string {
 	return result
 }
 
-// Style returns a unique list of font style
+// ForRune returns the fonts whose unicode range includes r
+func (ts *Typefaces) ForRune(r rune) []Typeface {
+	filtered := []Typeface{}
+	for _, v := range ts.Fonts {
+		if v.Covers(r) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
+
+// Subset returns a unique list of font subsets
+func (ts *Typefaces) Subset() []string {
+	result := []string{}
+	for _, v := range ts.Fonts {
+		if v.Subset == "" {
+			continue
+		}
+
+		if !isUniqueString(result, v.Subset) {
+			continue
+		}
+
+		result = append(result, v.Subset)
+	}
+	return result
+}
+
+// Style returns a unique list of font style, with the angles of oblique fonts
 func (ts *Typefaces) Style() []string {
 	result := []string{}
 	for _, v := range ts.Fonts {
-		if v.Style == "" {
+		style := v.FontStyle()
+		if style == "" {
 			continue
 		}
 
-		if !isUniqueString(result, v.Style) {
+		if !isUniqueString(result, style) {
 			continue
 		}
 
-		result = append(result, v.Style)
+		result = append(result, style)
 	}
 	return result
 }
 
-// Weight returns a unique list of font weights
-func (ts *Typefaces) Weight() []int {
-	result := []int{}
+// Weight returns a unique list of font weights and weight ranges
+func (ts *Typefaces) Weight() []AxisValue {
+	result := []AxisValue{}
 	for _, v := range ts.Fonts {
-		if v.Weight < 1 {
+		if v.Weight.Max < 1 {
 			continue
 		}
 
-		if !isUniqueInt(result, v.Weight) {
+		if !isUniqueAxisValue(result, v.Weight) {
 			continue
 		}
 
@@ -446,21 +497,24 @@ func (ts *Typefaces) Weight() []int {
 	return result
 }
 
-// URL returns a unique list of font URLs
+// URL returns a unique list of font URLs, including every source of each font
 func (ts *Typefaces) URL() []*url.URL {
 	result := []*url.URL{}
 	resultString := []string{}
 
 	for _, v := range ts.Fonts {
-		if v.Style == "" {
-			continue
-		}
+		for _, src := range v.AllSources() {
+			if src.IsLocal() {
+				continue
+			}
 
-		if !isUniqueString(resultString, v.URL.String()) {
-			continue
-		}
+			if !isUniqueString(resultString, src.URL.String()) {
+				continue
+			}
 
-		result = append(result, v.URL)
+			resultString = append(resultString, src.URL.String())
+			result = append(result, src.URL)
+		}
 	}
 	return result
 }
@@ -474,7 +528,7 @@ func (t *Typeface) MarshalJSON() ([]byte, error) {
 		FileName string `json:"filename"`
 		*tfAlias
 	}{
-		URL: t.URL.String(),
+		URL: urlString(t.URL),
 		Version: t.Version(),
 		FileName: t.FileName(),
 		tfAlias: (*tfAlias)(t),
@@ -494,6 +548,11 @@ func (t *Typeface) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	
+	t.URL = nil
+	if aux.URL == "" {
+		return nil
+	}
+
 	u, errURL := url.Parse(aux.URL)
 	if errURL != nil {
 		return errURL
@@ -504,96 +563,569 @@ func (t *Typeface) UnmarshalJSON(data []byte) error {
 }
 
 func (t *Typeface) String() string {
-	return fmt.Sprintf("%s %s %d", t.Family, t.Style, t.Weight)
+	return fmt.Sprintf("%s %s %s", t.Family, t.FontStyle(), t.Weight)
 }
 
-// Version returns the font version by parsing the URL
-func (t *Typeface) Version() string {
-	if t.URL == nil {
-		return ""
+// Covers reports whether the font is used for r, according to its unicode range
+func (t *Typeface) Covers(r rune) bool {
+	return t.Runes().Contains(r)
+}
+
+// Runes returns the set of characters the font is used for. Without a unicode range, this is every code point.
+func (t *Typeface) Runes() UnicodeRange {
+	if len(t.UnicodeRange) == 0 {
+		return allRunes
+	}
+	return t.UnicodeRange
+}
+
+// FontStyle returns the value of the font-style descriptor, such as italic or oblique 0deg 10deg
+func (t *Typeface) FontStyle() string {
+	if t.Style != "oblique" || t.Slant == nil {
+		return t.Style
 	}
+	return t.Style + " " + formatRange(*t.Slant, "deg")
+}
 
-	if t.URL.RawQuery != "" {
-		// /l/font?kit=L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI18&skey=ea73fc1e1d1dfd9a&v=v10#Domine
-		return t.URL.Query().Get("v")
+// matchStyle reports whether t matches the font-style value style
+func (t *Typeface) matchStyle(style string) bool {
+	name, slant, err := parseStyleFields(strings.Fields(style))
+	if err != nil {
+		return t.Style == style
+	}
+	if t.Style != name {
+		return false
+	}
+	if slant == nil {
+		return true
 	}
 
-	pathParts := strings.Split(t.URL.Path, "/")
-	if !strings.HasPrefix(pathParts[len(pathParts)-2], "v") {
-		return ""
+	fontSlant := AxisPoint(defaultObliqueAngle)
+	if t.Slant != nil {
+		fontSlant = *t.Slant
 	}
-	return pathParts[len(pathParts)-2]
+	return fontSlant.Contains(slant.Min) && fontSlant.Contains(slant.Max)
+}
+
+// Version returns the font version by parsing the URL
+func (t *Typeface) Version() string {
+	return t.FontURL().Version
 }
 
 // FileName returns the font filename by parsing the URL
 func (t *Typeface) FileName() string {
-	if t.URL == nil {
-		return ""
+	return t.FontURL().FileName()
+}
+
+// FontURL returns the URL broken down into its parts
+func (t *Typeface) FontURL() *FontURL {
+	return parseFontURL(t.URL, t.Format)
+}
+
+// CSS returns the CSS representation of a TypeFace
+func (t *Typeface) CSS() string {
+	return renderFontFace(fontFace{Subset: t.Subset, Decls: t.declarations(t.srcCSS(","), false)}, false)
+}
+
+// PrettyCSS is the human readable version of method CSS
+func (t *Typeface) PrettyCSS() string {
+	return renderFontFace(fontFace{Subset: t.Subset, Decls: t.declarations(t.srcCSS(",\n\t\t"), true)}, true)
+}
+
+// declarations returns the descriptors of the font face as name and value pairs, in the order they are rendered
+func (t *Typeface) declarations(src string, pretty bool) [][2]string {
+	result := [][2]string{}
+	if t.Family != "" {
+		fontFamily := t.Family
+		if !isIdent(t.Family) {
+			fontFamily = quote(t.Family)
+		}
+		result = append(result, [2]string{"font-family", fontFamily})
+	}
+	if t.Style != "" {
+		result = append(result, [2]string{"font-style", t.FontStyle()})
+	}
+	if t.Weight.Max > 0 {
+		result = append(result, [2]string{"font-weight", formatRange(t.Weight, "")})
+	}
+	if t.Stretch != nil {
+		result = append(result, [2]string{"font-stretch", formatRange(*t.Stretch, "%")})
+	}
+	if t.Display != "" {
+		result = append(result, [2]string{"font-display", t.Display})
+	}
+	if src != "" {
+		result = append(result, [2]string{"src", src})
 	}
 
-	if t.URL.RawQuery != "" {
-		// /l/font?kit=L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI18&skey=ea73fc1e1d1dfd9a&v=v10#Domine
-		kitUID := t.URL.Query().Get("kit")
-		if kitUID == "" {
-			return ""
+	sep := ","
+	if pretty {
+		sep = ", "
+	}
+	if len(t.UnicodeRange) > 0 {
+		result = append(result, [2]string{"unicode-range", strings.Join(t.UnicodeRange.Strings(), sep)})
+	}
+	if len(t.FeatureSettings) > 0 {
+		features := make([]string, len(t.FeatureSettings))
+		for i, v := range t.FeatureSettings {
+			features[i] = v.String()
 		}
-		return kitUID + ".svg"
+		result = append(result, [2]string{"font-feature-settings", strings.Join(features, sep)})
+	}
+	if t.SizeAdjust != nil {
+		result = append(result, [2]string{"size-adjust", formatPercentage(*t.SizeAdjust)})
 	}
+	if t.AscentOverride != nil {
+		result = append(result, [2]string{"ascent-override", formatPercentage(*t.AscentOverride)})
+	}
+	if t.DescentOverride != nil {
+		result = append(result, [2]string{"descent-override", formatPercentage(*t.DescentOverride)})
+	}
+	if t.LineGapOverride != nil {
+		result = append(result, [2]string{"line-gap-override", formatPercentage(*t.LineGapOverride)})
+	}
+	return append(result, otherDeclarations(t.Descriptors)...)
+}
 
-	pathParts := strings.Split(t.URL.Path, "/")
-	return pathParts[len(pathParts)-1]
+// faceKey identifies the font face t belongs to, regardless of its sources
+func (t *Typeface) faceKey() string {
+	decls := t.declarations("", false)
+	parts := make([]string, len(decls))
+	for i, d := range decls {
+		parts[i] = d[0] + ":" + d[1]
+	}
+	return t.Subset + "/" + strings.Join(parts, ";")
 }
 
-// CSS returns the CSS representation of a TypeFace
-func (t *Typeface) CSS() string {
-	cssTpl := `@font-face{font-family:%s;font-style:%s;font-weight:%d;src:url('%s') format('%s');`
+// IsLocal reports whether src refers to a locally installed font
+func (src Source) IsLocal() bool {
+	return src.URL == nil
+}
 
-	if len(t.UnicodeRange) > 0 {
-		cssTpl = cssTpl + "unicode-range:%s;"
+// CSS returns the representation of src in the src descriptor
+func (src Source) CSS() string {
+	if src.IsLocal() {
+		return fmt.Sprintf("local(%s)", quote(src.Local))
 	}
-	cssTpl = strings.TrimSuffix(cssTpl, ";")
-	cssTpl = cssTpl + "}"
 
-	fontFamily := t.Family
-	if strings.Contains(t.Family, " ") {
-		fontFamily = "'" + t.Family + "'"
+	result := ""
+	switch src.Format {
+	case "":
+		result = fmt.Sprintf("url(%s)", quote(src.URL.String()))
+	case "eot":
+		// IE6-8 would request everything after the URL if the query is not terminated with ?#iefix
+		u := *src.URL
+		if u.Fragment == "" {
+			u.ForceQuery = true
+			u.Fragment = "iefix"
+		}
+		result = fmt.Sprintf("url(%s) format('embedded-opentype')", quote(u.String()))
+	default:
+		result = fmt.Sprintf("url(%s) format(%s)", quote(src.URL.String()), quote(src.Format))
 	}
 
-	if len(t.UnicodeRange) > 0 {
-		return fmt.Sprintf(cssTpl, fontFamily, t.Style, t.Weight, t.URL, t.Format, strings.Join(t.UnicodeRange, ","))
+	if src.Tech != "" {
+		result = result + fmt.Sprintf(" tech(%s)", src.Tech)
 	}
+	return result
+}
 
-	return fmt.Sprintf(cssTpl, fontFamily, t.Style, t.Weight, t.URL, t.Format)
+// MarshalJSON returns a JSON representation of Source
+func (src Source) MarshalJSON() ([]byte, error) {
+	aux := struct {
+		URL string    `json:"url,omitempty"`
+		Local string  `json:"local,omitempty"`
+		Format string `json:"format,omitempty"`
+		Tech string   `json:"tech,omitempty"`
+	}{
+		Local: src.Local,
+		Format: src.Format,
+		Tech: src.Tech,
+	}
+	if src.URL != nil {
+		aux.URL = src.URL.String()
+	}
+	return json.Marshal(aux)
 }
 
-// PrettyCSS is the human readable version of method CSS
-func (t *Typeface) PrettyCSS() string {
-	cssTpl := `@font-face {
-	font-family: %s;
-	font-style: %s;
-	font-weight: %d;
-	src: url('%s') format('%s');`
+// UnmarshalJSON converts a JSON representation of Source to Source
+func (src *Source) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		URL string    `json:"url"`
+		Local string  `json:"local"`
+		Format string `json:"format"`
+		Tech string   `json:"tech"`
+	}{}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
 
-	if len(t.UnicodeRange) > 0 {
-		cssTpl = cssTpl + "\n\tunicode-range: %s;"
+	src.Local = aux.Local
+	src.Format = aux.Format
+	src.Tech = aux.Tech
+	src.URL = nil
+	if aux.URL != "" {
+		u, errURL := url.Parse(aux.URL)
+		if errURL != nil {
+			return errURL
+		}
+		src.URL = u
 	}
-	cssTpl = cssTpl + "\n}"
+	return nil
+}
 
-	fontFamily := t.Family
-	if strings.Contains(t.Family, " ") {
-		fontFamily = "'" + t.Family + "'"
+// AllSources returns the sources of the font face. A typeface without Sources has a single source made of URL and Format.
+func (t *Typeface) AllSources() []Source {
+	if len(t.Sources) > 0 {
+		return t.Sources
 	}
+	if t.URL == nil {
+		return []Source{}
+	}
+	return []Source{{URL: t.URL, Format: t.Format}}
+}
 
-	if len(t.UnicodeRange) > 0 {
-		return fmt.Sprintf(cssTpl, fontFamily, t.Style, t.Weight, t.URL, t.Format, strings.Join(t.UnicodeRange, ", "))
+// hasFormat reports whether any source of t has the format
+func (t *Typeface) hasFormat(format string) bool {
+	for _, src := range t.AllSources() {
+		if src.Format == format {
+			return true
+		}
 	}
+	return false
+}
+
+// withSource returns a copy of t with src as its only source
+func (t *Typeface) withSource(src Source) Typeface {
+	result := *t
+	result.URL = src.URL
+	result.Format = src.Format
+	result.Sources = nil
+	return result
+}
 
-	return fmt.Sprintf(cssTpl, fontFamily, t.Style, t.Weight, t.URL, t.Format)
+// srcCSS returns the value of the src descriptor
+func (t *Typeface) srcCSS(sep string) string {
+	sources := t.AllSources()
+	parts := make([]string, len(sources))
+	for i, src := range sources {
+		parts[i] = src.CSS()
+	}
+	return strings.Join(parts, sep)
 }
 
 // --- helpers ---
 
-func nextSig(s *scanner.Scanner) *scanner.Token {
+// parseSources parses the comma separated list of sources of the src descriptor, up to the end of the declaration
+func parseSources(s *tokenReader, lastGoodToken string) ([]Source, error) {
+	result := []Source{}
+	for {
+		src := Source{}
+		token := nextSig(s)
+		switch {
+		case token.Type == scanner.TokenURI:
+			urlString := unquote(strings.TrimSuffix(strings.TrimPrefix(token.Value, "url("), ")"))
+			u, errURL := url.Parse(urlString)
+			if errURL != nil {
+				return nil, fmt.Errorf("parse url failed after %s", lastGoodToken)
+			}
+			src.URL = u
+		case token.Type == scanner.TokenFunction && token.Value == "local(":
+			lastGoodToken = token.String()
+			args, errArgs := parseFunctionArgs(s, lastGoodToken)
+			if errArgs != nil {
+				return nil, errArgs
+			}
+			src.Local = strings.Join(args, " ")
+		default:
+			return nil, fmt.Errorf("expect <uri> or local( after %s but got %s", lastGoodToken, token.String())
+		}
+		lastGoodToken = token.String()
+
+		for {
+			token = nextSig(s)
+			if token.Type == scanner.TokenFunction && (token.Value == "format(" || token.Value == "tech(") {
+				lastGoodToken = token.String()
+				args, errArgs := parseFunctionArgs(s, lastGoodToken)
+				if errArgs != nil {
+					return nil, errArgs
+				}
+				if token.Value == "format(" {
+					src.Format = strings.Join(args, ",")
+				} else {
+					src.Tech = strings.Join(args, ", ")
+				}
+				continue
+			}
+			break
+		}
+
+		// EOT format
+		//   src: url(https://xxx.eot);
+		//   src: url(https://xxx.eot?#iefix) format('embedded-opentype');
+		if src.URL != nil && (src.Format == "" || src.Format == "embedded-opentype") &&
+			strings.HasSuffix(strings.ToLower(src.URL.Path), ".eot") {
+			src.Format = "eot"
+			if src.URL.Fragment == "iefix" {
+				src.URL.Fragment = ""
+			}
+			if src.URL.RawQuery == "" {
+				src.URL.ForceQuery = false
+			}
+		}
+		result = append(result, src)
+
+		if token.Type == scanner.TokenChar && token.Value == "," {
+			lastGoodToken = token.String()
+			continue
+		}
+		if err := endDeclaration(s, token, lastGoodToken); err != nil {
+			return nil, fmt.Errorf("expect , or ; after %s but got %s", lastGoodToken, token.String())
+		}
+		return result, nil
+	}
+}
+
+// parseFunctionArgs returns the comma separated arguments of a function, up to and including the )
+func parseFunctionArgs(s *tokenReader, lastGoodToken string) ([]string, error) {
+	result := []string{}
+	current := []string{}
+	for {
+		token := nextSig(s)
+		if token.Type == scanner.TokenEOF || token.Type == scanner.TokenError {
+			return nil, fmt.Errorf("unexpected EOF after %s", lastGoodToken)
+		}
+		if token.Type == scanner.TokenChar && (token.Value == ")" || token.Value == ",") {
+			if len(current) > 0 {
+				result = append(result, strings.Join(current, " "))
+			}
+			current = []string{}
+			if token.Value == ")" {
+				return result, nil
+			}
+			lastGoodToken = token.String()
+			continue
+		}
+		if token.Type != scanner.TokenString && token.Type != scanner.TokenIdent && token.Type != scanner.TokenNumber {
+			return nil, fmt.Errorf("unexpected %s after %s", token.String(), lastGoodToken)
+		}
+		current = append(current, unquote(token.Value))
+		lastGoodToken = token.String()
+	}
+}
+
+// fontFace is a font face rule ready to render
+type fontFace struct {
+	Subset string
+	Decls  [][2]string
+}
+
+// renderFontFace renders an @font-face rule
+func renderFontFace(face fontFace, pretty bool) string {
+	comment := ""
+	if isSubsetName(face.Subset) {
+		comment = "/* " + face.Subset + " */"
+	}
+
+	if !pretty {
+		parts := make([]string, len(face.Decls))
+		for i, d := range face.Decls {
+			parts[i] = d[0] + ":" + d[1]
+		}
+		return comment + "@font-face{" + strings.Join(parts, ";") + "}"
+	}
+
+	result := "@font-face {\n"
+	if comment != "" {
+		result = comment + "\n" + result
+	}
+	for _, d := range face.Decls {
+		result = result + "\t" + d[0] + ": " + d[1] + ";\n"
+	}
+	return result + "}"
+}
+
+// commentSubset returns the subset named by a comment such as /* latin-ext */, or an empty string for other comments
+func commentSubset(comment string) string {
+	name := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(comment, "/*"), "*/"))
+	if !isSubsetName(name) {
+		return ""
+	}
+	return name
+}
+
+// isSubsetName reports whether s looks like a css2 subset name, such as latin-ext or [12] for the slices of CJK fonts
+func isSubsetName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && !strings.ContainsRune("-_[]", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// fontExtensions maps the formats to file extensions
+var fontExtensions = map[string]string{
+	"woff2":             "woff2",
+	"woff":              "woff",
+	"truetype":          "ttf",
+	"ttf":               "ttf",
+	"opentype":          "otf",
+	"eot":               "eot",
+	"embedded-opentype": "eot",
+	"svg":               "svg",
+}
+
+// formatRank orders formats from the most legacy compatible to the least
+func formatRank(format string) int {
+	switch format {
+	case "eot":
+		return 0
+	case "woff2":
+		return 1
+	case "woff":
+		return 2
+	case "truetype", "ttf", "opentype":
+		return 3
+	case "svg":
+		return 5
+	default:
+		return 4
+	}
+}
+
+func containsSource(sl []Source, src Source) bool {
+	for _, v := range sl {
+		if v.Local == src.Local && urlString(v.URL) == urlString(src.URL) && v.Format == src.Format {
+			return true
+		}
+	}
+	return false
+}
+
+func urlString(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+// unquote removes the quotes around a CSS string and resolves its escapes
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		// up to 6 hex digits, optionally followed by a single whitespace
+		j := i + 1
+		for j < len(s) && j < i+7 && isHexDigit(s[j]) {
+			j++
+		}
+		if j == i+1 {
+			if s[j] != '\n' {
+				sb.WriteByte(s[j])
+			}
+			i = j
+			continue
+		}
+
+		code, _ := strconv.ParseUint(s[i+1:j], 16, 32)
+		if code == 0 || code > unicode.MaxRune || (code >= 0xD800 && code <= 0xDFFF) {
+			code = unicode.ReplacementChar
+		}
+		sb.WriteRune(rune(code))
+		if j < len(s) && (s[j] == ' ' || s[j] == '\t' || s[j] == '\n') {
+			j++
+		}
+		i = j - 1
+	}
+	return sb.String()
+}
+
+// quote returns s as a single quoted CSS string
+func quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('\'')
+	for _, r := range s {
+		switch {
+		case r == '\'' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			sb.WriteString(fmt.Sprintf("\\%x ", r))
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('\'')
+	return sb.String()
+}
+
+// isIdent reports whether s can be written as a CSS identifier without escapes
+func isIdent(s string) bool {
+	if s == "" || s[0] == '-' || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+	for _, r := range s {
+		if r != '-' && r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// tokenReader is a scanner that can push back one token
+type tokenReader struct {
+	*scanner.Scanner
+	back *scanner.Token
+}
+
+// Next returns the pushed back token if any, or the next token
+func (r *tokenReader) Next() *scanner.Token {
+	if r.back != nil {
+		t := r.back
+		r.back = nil
+		return t
+	}
+	return r.Scanner.Next()
+}
+
+func (r *tokenReader) unread(t *scanner.Token) {
+	r.back = t
+}
+
+// endDeclaration checks that token ends a declaration. The last declaration of a block may omit the ;, in which case
+// the } is pushed back for the caller.
+func endDeclaration(s *tokenReader, token *scanner.Token, lastGoodToken string) error {
+	if token.Type == scanner.TokenChar && token.Value == ";" {
+		return nil
+	}
+	if token.Type == scanner.TokenChar && token.Value == "}" {
+		s.unread(token)
+		return nil
+	}
+	return fmt.Errorf("expect ; after %s", lastGoodToken)
+}
+
+func nextSig(s *tokenReader) *scanner.Token {
 	for {
 		t := s.Next()
 		if t.Type == scanner.TokenS || t.Type == scanner.TokenComment {
@@ -615,7 +1147,7 @@ func isUniqueString(sl []string, s string) bool {
 	return !found
 }
 
-func isUniqueInt(sl []int, s int) bool {
+func isUniqueAxisValue(sl []AxisValue, s AxisValue) bool {
 	found := false
 	for _, r := range sl {
 		if s == r {
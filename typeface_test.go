@@ -0,0 +1,437 @@
+package gfont
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var testdataFormats = []string{"eot", "svg", "ttf", "woff2", "woff2old", "woffold"}
+
+// multiSourceCSS is hand-written CSS with the features Google does not serve
+const multiSourceCSS = `
+/* latin */
+@font-face {
+  font-family: 'Domine';
+  font-style: normal;
+  font-weight: 400;
+  src: url(https://fonts.gstatic.com/s/domine/v10/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1w.eot);
+  src: local('Domine Regular'), local(Domine-Regular),
+       url('https://fonts.gstatic.com/s/domine/v10/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1w.eot?#iefix') format('embedded-opentype'),
+       url(https://fonts.gstatic.com/s/domine/v10/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1g.woff2) format("woff2") tech(variations),
+       url(https://fonts.gstatic.com/s/domine/v10/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI10.ttf) format('truetype');
+  unicode-range: U+0000-00FF, U+0131, U+0152-0153;
+  font-display: swap;
+  font-stretch: condensed;
+  size-adjust: 90.5%;
+  ascent-override: 105%;
+  descent-override: normal;
+  line-gap-override: 0%;
+  font-feature-settings: "liga" 0, 'kern', "ss01" on;
+  font-variation-settings: "wght" 400;
+  font-named-instance: auto;
+}
+@font-face{font-family:Open Sans;font-style:italic;font-weight:700;src:url('https://fonts.gstatic.com/s/opensans/v18/x.woff') format('woff')}
+@font-face {
+  font-family: 'Roboto Flex';
+  font-style: normal;
+  font-weight: 1000 100;
+  font-stretch: condensed 151%;
+  src: url(https://fonts.gstatic.com/s/robotoflex/v9/y.woff2) format('woff2');
+}
+@font-face {
+  font-family: 'Recursive';
+  font-style: oblique 15deg -0.25turn;
+  font-weight: 300 1000;
+  src: url(https://fonts.gstatic.com/s/recursive/v23/z.woff2) format('woff2');
+}
+@font-face{font-family:Recursive;font-style:oblique;font-weight:400;src:url(https://fonts.gstatic.com/s/recursive/v23/w.woff2) format('woff2')}
+`
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	content, err := ioutil.ReadFile(filepath.Join("gfontc", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func mustUnmarshalCSS(t *testing.T, css string) Typefaces {
+	t.Helper()
+	var ts Typefaces
+	if err := UnmarshalCSS([]byte(css), &ts); err != nil {
+		t.Fatalf("UnmarshalCSS: %v\n%s", err, css)
+	}
+	return ts
+}
+
+func mustMarshalJSON(t *testing.T, ts Typefaces) string {
+	t.Helper()
+	content, err := json.Marshal(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// allTestdata returns the fonts of every testdata CSS file merged into one collection
+func allTestdata(t *testing.T) Typefaces {
+	t.Helper()
+	all := Typefaces{}
+	for _, f := range testdataFormats {
+		ts := mustUnmarshalCSS(t, string(readTestdata(t, f+".css")))
+		all.Fonts = append(all.Fonts, ts.Fonts...)
+	}
+	return all
+}
+
+func sortedURLs(ts Typefaces) []string {
+	result := []string{}
+	for _, u := range ts.URL() {
+		result = append(result, u.String())
+	}
+	sort.Strings(result)
+	return result
+}
+
+// multiSrcCSS has several src descriptors, the last of which lists local fonts and several files
+const multiSrcCSS = `@font-face {
+  font-family: 'Domine';
+  font-style: normal;
+  font-weight: 400;
+  src: url(https://fonts.gstatic.com/s/domine/v10/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1w.eot);
+  src: local('Domine Regular'), local(Domine-Regular),
+       url('https://fonts.gstatic.com/s/domine/v10/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1w.eot?#iefix') format('embedded-opentype'),
+       url(https://fonts.gstatic.com/s/domine/v10/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1g.woff2) format("woff2") tech(variations),
+       url(https://fonts.gstatic.com/s/domine/v10/L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI10.ttf) format('truetype');
+}
+`
+
+func TestMultipleSources(t *testing.T) {
+	var ts Typefaces
+	if err := UnmarshalCSS([]byte(multiSrcCSS), &ts); err != nil {
+		t.Fatal(err)
+	}
+	domine := ts.Fonts[0]
+
+	got := []string{}
+	for _, src := range domine.AllSources() {
+		if src.IsLocal() {
+			got = append(got, "local:"+src.Local)
+		} else {
+			got = append(got, src.Format+":"+path.Base(src.URL.Path))
+		}
+	}
+	want := []string{
+		"local:Domine Regular",
+		"local:Domine-Regular",
+		"eot:L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1w.eot",
+		"woff2:L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1g.woff2",
+		"truetype:L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI10.ttf",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	if domine.Sources[3].Tech != "variations" {
+		t.Errorf("got tech %q", domine.Sources[3].Tech)
+	}
+	// URL and Format are those of the first url source
+	if domine.Format != "eot" || path.Base(domine.URL.Path) != "L0xhDFMnlVwD4h3Lt9JWnbX3jG-2X3LAI1w.eot" {
+		t.Errorf("got %s %s", domine.Format, domine.URL)
+	}
+
+	var localOnly Typefaces
+	if err := UnmarshalCSS([]byte("@font-face {\n  font-family: 'Domine';\n  src: local(Domine);\n}\n"), &localOnly); err != nil {
+		t.Fatal(err)
+	}
+	if f := localOnly.Fonts[0]; f.URL != nil || len(f.AllSources()) != 1 || f.AllSources()[0].Local != "Domine" {
+		t.Errorf("got %+v", f)
+	}
+}
+
+func TestDownloadMultipleSources(t *testing.T) {
+	srv := newFontServer(t)
+	var ts Typefaces
+	css := "@font-face {\n  font-family: 'Domine';\n  font-style: normal;\n  font-weight: 400;\n  src: local(Domine), url(" + srv.URL +
+		"/s/domine/v20/a.woff2) format('woff2'), url(" + srv.URL + "/s/domine/v20/a.ttf) format('truetype');\n}\n"
+	if err := UnmarshalCSS([]byte(css), &ts); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := NewDownloader(t.TempDir()).Download(context.Background(), &ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, f := range manifest.Files {
+		got = append(got, f.Format+":"+f.Path)
+	}
+	if strings.Join(got, " ") != "woff2:domine/v20/a.woff2 truetype:domine/v20/a.ttf" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestUnmarshalCSSTestdata(t *testing.T) {
+	for _, f := range testdataFormats {
+		t.Run(f, func(t *testing.T) {
+			got := mustUnmarshalCSS(t, string(readTestdata(t, f+".css")))
+
+			var want Typefaces
+			if err := json.Unmarshal(readTestdata(t, f+".json"), &want); err != nil {
+				t.Fatal(err)
+			}
+			if mustMarshalJSON(t, got) != mustMarshalJSON(t, want) {
+				t.Errorf("got %s\nwant %s", mustMarshalJSON(t, got), mustMarshalJSON(t, want))
+			}
+		})
+	}
+}
+
+func TestTypefaceRoundTrip(t *testing.T) {
+	inputs := map[string]string{"multisource": multiSourceCSS}
+	for _, f := range testdataFormats {
+		inputs[f] = string(readTestdata(t, f+".css"))
+	}
+
+	for name, css := range inputs {
+		t.Run(name, func(t *testing.T) {
+			parsed := mustUnmarshalCSS(t, css)
+			want := mustMarshalJSON(t, parsed)
+
+			compact := []string{}
+			pretty := []string{}
+			for _, v := range parsed.Fonts {
+				compact = append(compact, v.CSS())
+				pretty = append(pretty, v.PrettyCSS())
+			}
+
+			for _, rendered := range []string{strings.Join(compact, ""), strings.Join(pretty, "\n")} {
+				got := mustMarshalJSON(t, mustUnmarshalCSS(t, rendered))
+				if got != want {
+					t.Errorf("parse->render->parse changed fonts\ngot  %s\nwant %s\ncss %s", got, want, rendered)
+				}
+			}
+		})
+	}
+}
+
+func TestTypefacesRoundTrip(t *testing.T) {
+	all := allTestdata(t)
+	all.Fonts = append(all.Fonts, mustUnmarshalCSS(t, multiSourceCSS).Fonts...)
+
+	renderers := map[string]func(*Typefaces) string{
+		"CSS":       (*Typefaces).CSS,
+		"PrettyCSS": (*Typefaces).PrettyCSS,
+	}
+	for name, render := range renderers {
+		t.Run(name, func(t *testing.T) {
+			first := render(&all)
+			parsed := mustUnmarshalCSS(t, first)
+			second := render(&parsed)
+			if first != second {
+				t.Errorf("render is not stable\nfirst  %s\nsecond %s", first, second)
+			}
+
+			reparsed := mustUnmarshalCSS(t, second)
+			if mustMarshalJSON(t, parsed) != mustMarshalJSON(t, reparsed) {
+				t.Errorf("parse is not stable\nfirst  %s\nsecond %s", mustMarshalJSON(t, parsed), mustMarshalJSON(t, reparsed))
+			}
+
+			// one source per format survives the merge
+			if len(parsed.Format()) != len(all.Format()) {
+				t.Errorf("got formats %v, want %v", parsed.Format(), all.Format())
+			}
+			for _, fam := range all.Family() {
+				for _, format := range all.Format() {
+					want := len(all.Select(format, fam, "", -1)) > 0
+					got := len(parsed.Select(format, fam, "", -1)) > 0
+					if got != want {
+						t.Errorf("%s %s: got %v, want %v", fam, format, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSelectWeightRange(t *testing.T) {
+	ts := mustUnmarshalCSS(t, multiSourceCSS)
+	flex := ts.Select("", "Roboto Flex", "", -1)
+	if len(flex) != 1 {
+		t.Fatalf("got %d fonts, want 1", len(flex))
+	}
+	if flex[0].Weight != AxisRange(100, 1000) || flex[0].Stretch == nil || *flex[0].Stretch != AxisRange(75, 151) {
+		t.Errorf("got weight %s stretch %v", flex[0].Weight, flex[0].Stretch)
+	}
+
+	for weight, want := range map[int]int{100: 1, 450: 2, 700: 3, 1000: 2, 50: 0} {
+		if got := len(ts.Select("", "", "", weight)); got != want {
+			t.Errorf("weight %d: got %d fonts, want %d", weight, got, want)
+		}
+	}
+}
+
+func TestSelectObliqueStyle(t *testing.T) {
+	ts := mustUnmarshalCSS(t, multiSourceCSS)
+	recursive := ts.Select("", "Recursive", "", -1)
+	if len(recursive) != 2 {
+		t.Fatalf("got %d fonts, want 2", len(recursive))
+	}
+	if got := recursive[0].FontStyle(); got != "oblique -90deg 15deg" {
+		t.Errorf("got style %s", got)
+	}
+
+	for style, want := range map[string]int{"oblique": 2, "oblique 0deg": 1, "oblique 14deg": 2, "oblique -10deg 10deg": 1,
+		"oblique 20deg": 0, "italic": 1, "normal": 2} {
+		if got := len(ts.Select("", "", style, -1)); got != want {
+			t.Errorf("style %s: got %d fonts, want %d", style, got, want)
+		}
+	}
+}
+
+func TestSelectSubset(t *testing.T) {
+	ts := mustUnmarshalCSS(t, string(readTestdata(t, "woff2.css")))
+	if got := strings.Join(ts.Subset(), ","); got != "vietnamese,latin-ext,latin" {
+		t.Errorf("got subsets %s", got)
+	}
+
+	selected := ts.Select("woff2", "Domine", "normal", 500, "latin", "latin-ext")
+	if len(selected) != 2 || selected[0].Subset != "latin-ext" || selected[1].Subset != "latin" {
+		t.Errorf("got %v", selected)
+	}
+	if got := len(ts.Select("", "", "", -1, "cyrillic")); got != 0 {
+		t.Errorf("got %d fonts for cyrillic, want 0", got)
+	}
+}
+
+func TestForRune(t *testing.T) {
+	ts := mustUnmarshalCSS(t, string(readTestdata(t, "woff2.css")))
+	for r, want := range map[rune]string{'A': "latin", 'ệ': "vietnamese", 'Ł': "latin-ext", 'Ж': ""} {
+		got := ""
+		for _, v := range ts.ForRune(r) {
+			got = got + v.Subset
+		}
+		if got != want {
+			t.Errorf("%c: got %q, want %q", r, got, want)
+		}
+	}
+
+	// a font without unicode-range is used for everything
+	ttf := mustUnmarshalCSS(t, string(readTestdata(t, "ttf.css")))
+	if got := ttf.ForRune('Ж'); len(got) == 0 {
+		t.Errorf("expect fonts without unicode range to cover every rune")
+	}
+}
+
+func TestForText(t *testing.T) {
+	ts := mustUnmarshalCSS(t, string(readTestdata(t, "woff2.css")))
+	ts.Fonts = append(ts.Fonts, mustUnmarshalCSS(t, string(readTestdata(t, "ttf.css"))).Fonts...)
+
+	coverage := ts.ForText("Tiếng Việt\nЖ")
+	if len(coverage.Missing) != 0 {
+		t.Errorf("got missing %s, want none", coverage.Missing)
+	}
+
+	// woff2 needs latin and vietnamese and lacks cyrillic, each ttf file covers everything
+	if len(coverage.Faces) != 5 {
+		t.Fatalf("got %d faces, want 5", len(coverage.Faces))
+	}
+	subsets := []string{}
+	for _, v := range coverage.Faces[0].Fonts {
+		subsets = append(subsets, v.Subset)
+	}
+	if got := strings.Join(subsets, ","); got != "vietnamese,latin" {
+		t.Errorf("got subsets %s, want vietnamese,latin", got)
+	}
+	if got := coverage.Faces[0].Missing.String(); got != "U+0416" {
+		t.Errorf("got missing %s, want U+0416", got)
+	}
+	for _, fc := range coverage.Faces[1:] {
+		if len(fc.Fonts) != 1 || len(fc.Missing) != 0 {
+			t.Errorf("%s %s: got %d fonts, missing %s", fc.Family, fc.Weight, len(fc.Fonts), fc.Missing)
+		}
+	}
+	if len(coverage.Fonts) != 6 {
+		t.Errorf("got %d fonts, want 6", len(coverage.Fonts))
+	}
+
+	woff2 := Typefaces{Fonts: ts.Select("woff2", "", "", -1)}
+	if got := woff2.ForText("Жук").Missing.String(); got != "U+0416, U+043A, U+0443" {
+		t.Errorf("got missing %s", got)
+	}
+}
+
+func TestTextSubset(t *testing.T) {
+	q := NewQuery().Family("Domine", AxisWeight).Tuple(AxisPoint(700)).SetText("Hello, 世界 +&")
+	if got, want := q.Encode(), "family=Domine:wght@700&text=Hello%2C%20%E4%B8%96%E7%95%8C%20%2B%26"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	parsed, err := ParseQuery(q.URL(""))
+	if err != nil || parsed.Text != q.Text {
+		t.Errorf("got text %q, %v", parsed.Text, err)
+	}
+
+	ts := mustUnmarshalCSS(t, `@font-face{font-family:Domine;font-weight:700;src:url(https://fonts.gstatic.com/l/font?kit=L0x8DFMnlVwD4h3&skey=ea73fc1e1d1dfd9a&v=v20) format('woff2')}`)
+	ts.Fonts = append(ts.Fonts, mustUnmarshalCSS(t, string(readTestdata(t, "svg.css"))).Fonts...)
+	if !ts.Fonts[0].TextSubset || ts.Fonts[0].FileName() != "L0x8DFMnlVwD4h3.woff2" || ts.Fonts[0].Version() != "v20" {
+		t.Errorf("got %v %s %s", ts.Fonts[0].TextSubset, ts.Fonts[0].FileName(), ts.Fonts[0].Version())
+	}
+	if ts.Fonts[1].TextSubset || !strings.HasSuffix(ts.Fonts[1].FileName(), ".svg") {
+		t.Errorf("svg font: got %v %s", ts.Fonts[1].TextSubset, ts.Fonts[1].FileName())
+	}
+}
+
+func TestTypefacesJSONRoundTrip(t *testing.T) {
+	all := allTestdata(t)
+	all.Fonts = append(all.Fonts, mustUnmarshalCSS(t, multiSourceCSS).Fonts...)
+	want := mustMarshalJSON(t, all)
+
+	var decoded Typefaces
+	if err := json.Unmarshal([]byte(want), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustMarshalJSON(t, decoded); got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+	if got := decoded.CSS(); got != all.CSS() {
+		t.Errorf("got %s\nwant %s", got, all.CSS())
+	}
+	if strings.Join(sortedURLs(decoded), " ") != strings.Join(sortedURLs(all), " ") {
+		t.Errorf("got urls %v, want %v", sortedURLs(decoded), sortedURLs(all))
+	}
+}
+
+func FuzzUnmarshalCSS(f *testing.F) {
+	for _, name := range testdataFormats {
+		content, err := ioutil.ReadFile(filepath.Join("gfontc", "testdata", name+".css"))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(content))
+	}
+	f.Add(multiSourceCSS)
+
+	f.Fuzz(func(t *testing.T, css string) {
+		var parsed Typefaces
+		if err := UnmarshalCSS([]byte(css), &parsed); err != nil {
+			return
+		}
+
+		for _, render := range []func(*Typefaces) string{(*Typefaces).CSS, (*Typefaces).PrettyCSS} {
+			first := render(&parsed)
+			var reparsed Typefaces
+			if err := UnmarshalCSS([]byte(first), &reparsed); err != nil {
+				t.Fatalf("cannot parse rendered css: %v\ninput %q\nrendered %q", err, css, first)
+			}
+			if second := render(&reparsed); second != first {
+				t.Fatalf("render is not stable\ninput %q\nfirst  %q\nsecond %q", css, first, second)
+			}
+		}
+	})
+}
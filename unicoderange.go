@@ -0,0 +1,252 @@
+package gfont
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// RuneRange is the code points from First to Last, inclusive
+type RuneRange struct {
+	First rune
+	Last  rune
+}
+
+// String returns the CSS representation, such as U+0131 or U+0000-00FF
+func (r RuneRange) String() string {
+	if r.First == r.Last {
+		return fmt.Sprintf("U+%04X", r.First)
+	}
+	return fmt.Sprintf("U+%04X-%04X", r.First, r.Last)
+}
+
+// UnicodeRange is a set of code points, such as the value of the unicode-range descriptor. It is kept as sorted
+// intervals that neither overlap nor touch, so that two equal sets always have the same representation.
+type UnicodeRange []RuneRange
+
+// NewUnicodeRange returns the set of code points in ranges
+func NewUnicodeRange(ranges ...RuneRange) UnicodeRange {
+	sorted := make([]RuneRange, 0, len(ranges))
+	for _, r := range ranges {
+		if r.First > r.Last {
+			r.First, r.Last = r.Last, r.First
+		}
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].First < sorted[j].First
+	})
+
+	result := UnicodeRange{}
+	for _, r := range sorted {
+		last := len(result) - 1
+		if last >= 0 && r.First <= result[last].Last+1 {
+			if r.Last > result[last].Last {
+				result[last].Last = r.Last
+			}
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// ParseUnicodeRange parses a comma separated list of ranges, such as U+0000-00FF, U+0131, U+4??
+func ParseUnicodeRange(s string) (UnicodeRange, error) {
+	ranges := []RuneRange{}
+	for _, token := range strings.Split(s, ",") {
+		r, err := parseRuneRange(strings.TrimSpace(token))
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return NewUnicodeRange(ranges...), nil
+}
+
+// UnicodeRangeOf returns the set of code points used in text
+func UnicodeRangeOf(text string) UnicodeRange {
+	ranges := []RuneRange{}
+	for _, r := range text {
+		if r == utf8.RuneError {
+			continue
+		}
+		ranges = append(ranges, RuneRange{First: r, Last: r})
+	}
+	return NewUnicodeRange(ranges...)
+}
+
+// Contains reports whether r is in the set
+func (ur UnicodeRange) Contains(r rune) bool {
+	i := sort.Search(len(ur), func(i int) bool {
+		return ur[i].Last >= r
+	})
+	return i < len(ur) && ur[i].First <= r
+}
+
+// Len returns the number of code points in the set
+func (ur UnicodeRange) Len() int {
+	n := 0
+	for _, r := range ur {
+		n += int(r.Last-r.First) + 1
+	}
+	return n
+}
+
+// Runes returns the code points in the set, in order. A set can hold over a million code points, so prefer Contains
+// or the set operations on intervals for large sets.
+func (ur UnicodeRange) Runes() []rune {
+	result := make([]rune, 0, ur.Len())
+	for _, r := range ur {
+		for c := r.First; c <= r.Last; c++ {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// Union returns the code points in ur or other
+func (ur UnicodeRange) Union(other UnicodeRange) UnicodeRange {
+	return NewUnicodeRange(append(append([]RuneRange{}, ur...), other...)...)
+}
+
+// Intersect returns the code points in both ur and other
+func (ur UnicodeRange) Intersect(other UnicodeRange) UnicodeRange {
+	result := UnicodeRange{}
+	for i, j := 0, 0; i < len(ur) && j < len(other); {
+		first, last := ur[i].First, ur[i].Last
+		if other[j].First > first {
+			first = other[j].First
+		}
+		if other[j].Last < last {
+			last = other[j].Last
+		}
+		if first <= last {
+			result = append(result, RuneRange{First: first, Last: last})
+		}
+
+		if ur[i].Last < other[j].Last {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
+
+// Subtract returns the code points in ur that are not in other
+func (ur UnicodeRange) Subtract(other UnicodeRange) UnicodeRange {
+	result := UnicodeRange{}
+	j := 0
+	for _, r := range ur {
+		first := r.First
+		for ; j < len(other) && other[j].Last < first; j++ {
+		}
+		for k := j; k < len(other) && other[k].First <= r.Last; k++ {
+			if other[k].First > first {
+				result = append(result, RuneRange{First: first, Last: other[k].First - 1})
+			}
+			first = other[k].Last + 1
+		}
+		if first <= r.Last {
+			result = append(result, RuneRange{First: first, Last: r.Last})
+		}
+	}
+	return result
+}
+
+// Strings returns the CSS representation of each interval
+func (ur UnicodeRange) Strings() []string {
+	result := make([]string, len(ur))
+	for i, r := range ur {
+		result[i] = r.String()
+	}
+	return result
+}
+
+// String returns the value of the unicode-range descriptor, such as U+0000-00FF, U+0131
+func (ur UnicodeRange) String() string {
+	return strings.Join(ur.Strings(), ", ")
+}
+
+// MarshalJSON returns a list of ranges, such as ["U+0000-00FF","U+0131"]
+func (ur UnicodeRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ur.Strings())
+}
+
+// UnmarshalJSON accepts a list of ranges in CSS representation, including wildcard ranges such as U+4??
+func (ur *UnicodeRange) UnmarshalJSON(data []byte) error {
+	var tokens []string
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return err
+	}
+	if tokens == nil {
+		*ur = nil
+		return nil
+	}
+
+	ranges := []RuneRange{}
+	for _, token := range tokens {
+		r, err := parseRuneRange(token)
+		if err != nil {
+			return err
+		}
+		ranges = append(ranges, r)
+	}
+	*ur = NewUnicodeRange(ranges...)
+	return nil
+}
+
+// allRunes is the range of a font face without unicode-range
+var allRunes = UnicodeRange{{First: 0, Last: utf8.MaxRune}}
+
+// parseRuneRange parses a single range, such as U+0131, U+0000-00FF or U+4??
+func parseRuneRange(s string) (RuneRange, error) {
+	if len(s) < 3 || (s[0] != 'U' && s[0] != 'u') || s[1] != '+' {
+		return RuneRange{}, fmt.Errorf("invalid unicode range %q", s)
+	}
+	body := s[2:]
+
+	var first, last string
+	if i := strings.Index(body, "-"); i >= 0 {
+		first, last = body[:i], body[i+1:]
+	} else if strings.HasSuffix(body, "?") {
+		// U+4?? is U+400-4FF
+		digits := strings.TrimRight(body, "?")
+		if strings.Contains(digits, "?") {
+			return RuneRange{}, fmt.Errorf("invalid unicode range %q", s)
+		}
+		wildcards := len(body) - len(digits)
+		first = digits + strings.Repeat("0", wildcards)
+		last = digits + strings.Repeat("F", wildcards)
+	} else {
+		first, last = body, body
+	}
+
+	r := RuneRange{}
+	for i, part := range []string{first, last} {
+		if len(part) == 0 || len(part) > 6 {
+			return RuneRange{}, fmt.Errorf("invalid unicode range %q", s)
+		}
+		v, err := strconv.ParseUint(part, 16, 32)
+		if err != nil {
+			return RuneRange{}, fmt.Errorf("invalid unicode range %q", s)
+		}
+		if i == 0 {
+			r.First = rune(v)
+		} else {
+			r.Last = rune(v)
+		}
+	}
+
+	if r.Last > utf8.MaxRune {
+		r.Last = utf8.MaxRune
+	}
+	if r.First > r.Last {
+		return RuneRange{}, fmt.Errorf("invalid unicode range %q", s)
+	}
+	return r, nil
+}
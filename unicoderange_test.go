@@ -0,0 +1,81 @@
+package gfont
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func mustParseUnicodeRange(t *testing.T, s string) UnicodeRange {
+	t.Helper()
+	ur, err := ParseUnicodeRange(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ur
+}
+
+func TestParseUnicodeRange(t *testing.T) {
+	tests := map[string]string{
+		"U+0000-00FF":                 "U+0000-00FF",
+		"U+0152-0153, U+0131, U+0100": "U+0100, U+0131, U+0152-0153",
+		"U+4??":                       "U+0400-04FF",
+		"u+0041-005a, U+0030-0040":    "U+0030-005A",
+		"U+1F600-1F64F, U+1F650":      "U+1F600-1F650",
+		"U+10FF00-11FFFF":             "U+10FF00-10FFFF",
+	}
+	for in, want := range tests {
+		if got := mustParseUnicodeRange(t, in).String(); got != want {
+			t.Errorf("%s: got %s, want %s", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "0041", "U+", "U+4?1", "U+0050-0041", "U+1234567", "U+4??-4FF"} {
+		if _, err := ParseUnicodeRange(in); err == nil {
+			t.Errorf("%q: expect error", in)
+		}
+	}
+}
+
+func TestUnicodeRangeOperations(t *testing.T) {
+	a := mustParseUnicodeRange(t, "U+0000-00FF, U+0131, U+0152-0153")
+	b := mustParseUnicodeRange(t, "U+0080-0140, U+0153")
+
+	if !a.Contains('A') || !a.Contains(0x131) || a.Contains(0x130) || a.Contains(0x154) {
+		t.Errorf("Contains is wrong for %s", a)
+	}
+	if a.Len() != 259 {
+		t.Errorf("got length %d, want 259", a.Len())
+	}
+
+	tests := []struct {
+		name string
+		got  UnicodeRange
+		want string
+	}{
+		{"union", a.Union(b), "U+0000-0140, U+0152-0153"},
+		{"intersect", a.Intersect(b), "U+0080-00FF, U+0131, U+0153"},
+		{"subtract", a.Subtract(b), "U+0000-007F, U+0152"},
+		{"subtract reverse", b.Subtract(a), "U+0100-0130, U+0132-0140"},
+		{"subtract all", a.Subtract(allRunes), ""},
+		{"text", UnicodeRangeOf("hello, wörld"), "U+0020, U+002C, U+0064-0065, U+0068, U+006C, U+006F, U+0072, U+0077, U+00F6"},
+	}
+	for _, tt := range tests {
+		if got := tt.got.String(); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnicodeRangeJSON(t *testing.T) {
+	var ur UnicodeRange
+	if err := json.Unmarshal([]byte(`["U+0152-0153","U+4??","U+0000-00FF"]`), &ur); err != nil {
+		t.Fatal(err)
+	}
+	content, err := json.Marshal(ur)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `["U+0000-00FF","U+0152-0153","U+0400-04FF"]`; string(content) != want {
+		t.Errorf("got %s, want %s", content, want)
+	}
+}